@@ -0,0 +1,95 @@
+/*
+Command migrate-meal-keys rewrites the keys of all stored meals to the normalized meal id.
+Meals that were stored before the names got normalized keep their raw name hash as key,
+so an edit in whitespace or case would otherwise still produce a new meal.
+The source is part of the meal id, meals that were stored before their source was recorded
+are assigned to the default source.
+Change events that refer to a migrated meal are pointed to its new key, a meal whose new key could not be stored
+keeps its old key and lets the command exit with an error.
+
+Usage:
+
+	go run ./cmd/migrate-meal-keys [-dry-run]
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"log"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only print the meals whose key would be migrated")
+	flag.Parse()
+
+	collectionName := config.Get().MealCollectionName
+	meals := make([]webcrawler.Meal, 0)
+	if err := persister.ReadAllDocuments(collectionName, nil, &meals); err != nil {
+		log.Fatal(err)
+	}
+
+	migrated, merged, failed, relinked := 0, 0, 0, 0
+	for _, meal := range meals {
+		// meals stored before their source was recorded were crawled from the default source
+		if meal.Source == "" {
+			meal.Source = webcrawler.DefaultSourceName
+		}
+		oldId := meal.Id
+		newId := webcrawler.MealId(meal.Source, meal.Date, meal.Name)
+		if oldId == newId {
+			continue
+		}
+
+		fmt.Printf("%s %s: %s -> %s\n", meal.Date, meal.Name, oldId, newId)
+		if *dryRun {
+			continue
+		}
+
+		// a meal with the new key was already crawled again, the old one is a duplicate
+		if persister.DocumentExists(collectionName, newId, nil) {
+			merged++
+		} else {
+			meal.Id = newId
+			persister.PersistDocument(collectionName, meal, nil)
+
+			// the persister only logs a failed write, the old meal is kept unless the new one was stored
+			if !persister.DocumentExists(collectionName, newId, nil) {
+				fmt.Printf("%s %s: storing the meal with the key %s failed, the key %s is kept\n", meal.Date, meal.Name, newId, oldId)
+				failed++
+				continue
+			}
+			migrated++
+		}
+		relinked += relinkChanges(oldId, newId)
+		persister.RemoveDocuments(collectionName, []string{oldId}, nil)
+	}
+
+	fmt.Printf("%d meals checked, %d keys migrated, %d duplicates removed, %d failed, %d change events relinked\n",
+		len(meals), migrated, merged, failed, relinked)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// Points the change events of a meal to its new key
+// The meals the events recorded as previous and current version keep the key they had at that time
+// Returns the number of relinked change events
+func relinkChanges(oldId string, newId string) int {
+	collectionName := config.Get().ChangeCollectionName
+	changes := make([]webcrawler.MealChange, 0)
+	if err := persister.ReadDocumentsByAttribute(collectionName, "mealId", []string{oldId}, nil, &changes); err != nil {
+		log.Printf("Reading the change events of the meal %s failed: %s", oldId, err)
+		return 0
+	}
+
+	for i := range changes {
+		changes[i].MealId = newId
+		persister.PersistDocument(collectionName, changes[i], nil)
+	}
+	return len(changes)
+}
@@ -0,0 +1,82 @@
+/*
+Command replay runs the current parser of a menu source over archived snapshots.
+It is used to check a parser fix against all pages that were crawled before.
+
+Usage:
+
+	go run ./cmd/replay [-dir archive] [-source cgm] [-snapshot id] [-v]
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"log"
+	"os"
+)
+
+func main() {
+	directory := flag.String("dir", config.Get().ArchiveDirectory, "directory of the snapshot archive")
+	sourceName := flag.String("source", webcrawler.DefaultSourceName, "name of the menu source whose snapshots are replayed")
+	snapshotId := flag.String("snapshot", "", "id of a single snapshot to replay, all snapshots are replayed if blank")
+	verbose := flag.Bool("v", false, "print every parsed meal")
+	flag.Parse()
+
+	if *directory == "" {
+		log.Fatal("No archive directory configured, set ARCHIVE_DIRECTORY or pass -dir")
+	}
+
+	archive, err := webcrawler.NewArchive(*directory)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	snapshotIds := []string{*snapshotId}
+	if *snapshotId == "" {
+		snapshotIds, err = archive.SnapshotIds(*sourceName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	failures := 0
+	for _, id := range snapshotIds {
+		if !replaySnapshot(archive, *sourceName, id, *verbose) {
+			failures++
+		}
+	}
+
+	fmt.Printf("replayed %d snapshots, %d failed\n", len(snapshotIds), failures)
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// Replays a single snapshot and prints its results
+// Returns false if the snapshot could not be parsed
+func replaySnapshot(archive *webcrawler.Archive, sourceName string, snapshotId string, verbose bool) bool {
+	meals, report, err := webcrawler.Replay(archive, sourceName, snapshotId)
+	if err != nil {
+		fmt.Printf("%s FAILED %s\n", snapshotId, err)
+		return false
+	}
+
+	fmt.Printf("%s %d meals, %d skipped nodes, %d warnings\n",
+		snapshotId, len(meals), len(report.SkippedNodes), len(report.Warnings))
+
+	for _, skippedNode := range report.SkippedNodes {
+		fmt.Printf("    skipped %s[%d]: %s\n", skippedNode.Selector, skippedNode.Index, skippedNode.Reason)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("    warning: %s\n", warning)
+	}
+	if verbose {
+		for _, meal := range meals {
+			fmt.Printf("    %s %s %s\n", meal.Date, meal.Name, meal.Price)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+/*
+Command validate-profile runs a selector profile against a saved page of its menu source.
+It prints every extracted meal and every node the profile could not parse,
+so a profile for a redesigned page can be checked before it is put into the profile directory.
+
+Usage:
+
+	go run ./cmd/validate-profile -profile profiles/cgm-v1.yaml -page webcrawler/bistro.html
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	profileFile := flag.String("profile", "", "yaml file of the selector profile to validate")
+	pageFile := flag.String("page", "", "saved html page of the menu source")
+	flag.Parse()
+
+	if *profileFile == "" || *pageFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	profile, err := webcrawler.LoadProfile(*profileFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := webcrawler.GetSource(profile.Source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profiledSource, ok := source.(webcrawler.ProfiledSource)
+	if !ok {
+		log.Fatalf("the source %s does not support selector profiles", profile.Source)
+	}
+
+	page, err := os.Open(*pageFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer page.Close()
+
+	meals, report, err := profiledSource.WithProfile(profile).ParseWeek(page)
+	printReport(report)
+	if err != nil {
+		fmt.Printf("FAILED %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, meal := range meals {
+		fmt.Printf("%s %s %s\n", meal.Date, meal.Name, meal.Price)
+		for _, supplement := range meal.MandatorySupplements {
+			fmt.Printf("    with %s\n", supplement.Name)
+		}
+		for _, supplement := range meal.OptionalSupplements {
+			fmt.Printf("    optional %s %s\n", supplement.Name, supplement.Price)
+		}
+		if meal.LowKcal || len(meal.Allergens) > 0 || len(meal.Additives) > 0 {
+			fmt.Printf("    lowKcal %t, allergens %s, additives %s\n",
+				meal.LowKcal, strings.Join(meal.Allergens, ","), strings.Join(meal.Additives, ","))
+		}
+	}
+
+	fmt.Printf("profile %s version %d extracted %d meals, %d skipped nodes, %d warnings\n",
+		profile.Source, profile.Version, len(meals), len(report.SkippedNodes), len(report.Warnings))
+}
+
+// Prints the days, skipped nodes and warnings of a parsing
+func printReport(report webcrawler.ParseReport) {
+	for _, day := range report.Days {
+		fmt.Printf("day %s %s %s\n", day.Date, day.State, day.Reason)
+	}
+	for _, skippedNode := range report.SkippedNodes {
+		fmt.Printf("skipped %s[%d]: %s\n", skippedNode.Selector, skippedNode.Index, skippedNode.Reason)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+}
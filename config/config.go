@@ -1,24 +1,83 @@
 package config
 
 import (
+	"fmt"
 	"github.com/caarlos0/env"
 	"github.com/joho/godotenv"
+	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 )
 
 type Config struct {
-	BistroUrl                 string `env:"BISTRO_URL"`
-	DatabaseAddress           string `env:"DATABASE_ADDRESS"`
-	DatabaseName              string `env:"DATABASE_NAME"`
-	DatabaseUser              string `env:"DATABASE_USER"`
-	DatabasePassword          string `env:"DATABASE_PASSWORD"`
-	MealCollectionName        string `env:"MEAL_COLLECTION_NAME"`
-	JobCollectionName         string `env:"JOB_COLLECTION_NAME"`
-	JobSchedulerTickInSeconds uint64 `env:"JOB_SCHEDULER_TICK_IN_SECONDS"`
-	RestApiPort               uint64 `env:"REST_API_PORT"`
-	SwaggerApiDocLocation     string `env:"SWAGGER_API_DOC_LOCATION"`
+	BistroUrl                  string   `env:"BISTRO_URL"`
+	SourceLocations            []string `env:"SOURCE_LOCATIONS"`
+	DatabaseAddress            string   `env:"DATABASE_ADDRESS"`
+	DatabaseName               string   `env:"DATABASE_NAME"`
+	DatabaseUser               string   `env:"DATABASE_USER"`
+	DatabasePassword           string   `env:"DATABASE_PASSWORD"`
+	MealCollectionName         string   `env:"MEAL_COLLECTION_NAME"`
+	JobCollectionName          string   `env:"JOB_COLLECTION_NAME"`
+	ChangeCollectionName       string   `env:"CHANGE_COLLECTION_NAME" envDefault:"changes"`
+	DayCollectionName          string   `env:"DAY_COLLECTION_NAME" envDefault:"days"`
+	BaselineCollectionName     string   `env:"BASELINE_COLLECTION_NAME" envDefault:"baselines"`
+	DriftCollectionName        string   `env:"DRIFT_COLLECTION_NAME" envDefault:"drifts"`
+	DietOverrideCollectionName string   `env:"DIET_OVERRIDE_COLLECTION_NAME" envDefault:"dietOverrides"`
+	ValidatorCollectionName    string   `env:"VALIDATOR_COLLECTION_NAME" envDefault:"validators"`
+	JobSchedulerTickInSeconds  uint64   `env:"JOB_SCHEDULER_TICK_IN_SECONDS"`
+	JobTimeoutInSeconds        uint64   `env:"JOB_TIMEOUT_IN_SECONDS" envDefault:"300"`
+	JobLeaseTimeoutInSeconds   uint64   `env:"JOB_LEASE_TIMEOUT_IN_SECONDS" envDefault:"600"`
+	JobQueue                   string   `env:"JOB_QUEUE" envDefault:"memory"`
+	JobWorkers                 uint64   `env:"JOB_WORKERS" envDefault:"1"`
+	JobWorkersPerSource        uint64   `env:"JOB_WORKERS_PER_SOURCE"`
+	JobDrainTimeoutInSeconds   uint64   `env:"JOB_DRAIN_TIMEOUT_IN_SECONDS" envDefault:"60"`
+	JobRetryAttempts           uint64   `env:"JOB_RETRY_ATTEMPTS" envDefault:"3"`
+	JobRetryDelayInSeconds     uint64   `env:"JOB_RETRY_DELAY_IN_SECONDS" envDefault:"30"`
+	JobRetryMaxDelayInSeconds  uint64   `env:"JOB_RETRY_MAX_DELAY_IN_SECONDS" envDefault:"900"`
+	JobRetryErrorClasses       []string `env:"JOB_RETRY_ERROR_CLASSES" envDefault:"timeout,network,server"`
+	RestApiPort                uint64   `env:"REST_API_PORT"`
+	SwaggerApiDocLocation      string   `env:"SWAGGER_API_DOC_LOCATION"`
+
+	HttpTimeoutInSeconds            uint64 `env:"HTTP_TIMEOUT_IN_SECONDS" envDefault:"30"`
+	HttpRetryAttempts               uint64 `env:"HTTP_RETRY_ATTEMPTS" envDefault:"3"`
+	HttpRetryDelayInMilliseconds    uint64 `env:"HTTP_RETRY_DELAY_IN_MILLISECONDS" envDefault:"500"`
+	HttpRetryMaxDelayInMilliseconds uint64 `env:"HTTP_RETRY_MAX_DELAY_IN_MILLISECONDS" envDefault:"10000"`
+	HttpUserAgent                   string `env:"HTTP_USER_AGENT" envDefault:"RateMyBistroCrawler/1.0"`
+	HttpProxyUrl                    string `env:"HTTP_PROXY_URL"`
+
+	HttpRespectRobots     bool    `env:"HTTP_RESPECT_ROBOTS" envDefault:"true"`
+	HttpRequestsPerSecond float64 `env:"HTTP_REQUESTS_PER_SECOND" envDefault:"1"`
+	HttpBurst             uint64  `env:"HTTP_BURST" envDefault:"1"`
+	HttpCacheTtlInSeconds uint64  `env:"HTTP_CACHE_TTL_IN_SECONDS" envDefault:"600"`
+
+	HttpAuthMethod          string   `env:"HTTP_AUTH_METHOD"` // blank, basic, form or certificate
+	HttpAuthHosts           []string `env:"HTTP_AUTH_HOSTS"`
+	HttpAuthUser            string   `env:"HTTP_AUTH_USER"`
+	HttpAuthUserFile        string   `env:"HTTP_AUTH_USER_FILE"`
+	HttpAuthPassword        string   `env:"HTTP_AUTH_PASSWORD"`
+	HttpAuthPasswordFile    string   `env:"HTTP_AUTH_PASSWORD_FILE"`
+	HttpAuthLoginUrl        string   `env:"HTTP_AUTH_LOGIN_URL"`
+	HttpAuthUserField       string   `env:"HTTP_AUTH_USER_FIELD" envDefault:"username"`
+	HttpAuthPasswordField   string   `env:"HTTP_AUTH_PASSWORD_FIELD" envDefault:"password"`
+	HttpAuthSessionCookie   string   `env:"HTTP_AUTH_SESSION_COOKIE"`
+	HttpAuthCertificateFile string   `env:"HTTP_AUTH_CERTIFICATE_FILE"`
+	HttpAuthKeyFile         string   `env:"HTTP_AUTH_KEY_FILE"`
+
+	ArchiveDirectory string `env:"ARCHIVE_DIRECTORY"`
+
+	ImageDirectory       string   `env:"IMAGE_DIRECTORY"`
+	ImageThumbnailWidths []uint64 `env:"IMAGE_THUMBNAIL_WIDTHS" envDefault:"160,480"`
+	ImageMaxPixels       uint64   `env:"IMAGE_MAX_PIXELS" envDefault:"40000000"`
+
+	ProfileDirectory string   `env:"PROFILE_DIRECTORY" envDefault:"profiles"`
+	SourceProfiles   []string `env:"SOURCE_PROFILES"`
+
+	DriftSimilarityThreshold float64 `env:"DRIFT_SIMILARITY_THRESHOLD" envDefault:"0.8"`
+
+	DetailPageConcurrency uint64 `env:"DETAIL_PAGE_CONCURRENCY" envDefault:"4"`
+
+	DietLexiconFile string `env:"DIET_LEXICON_FILE" envDefault:"lexicons/diet.yaml"`
 }
 
 var cfg Config
@@ -42,6 +101,18 @@ func Get() Config {
 	return cfg
 }
 
+// Resolves the location of the named menu source
+// Locations are configured as 'name=location' pairs, sources without an entry fall back to the bistro url
+func (cfg Config) SourceLocation(sourceName string) string {
+	for _, sourceLocation := range cfg.SourceLocations {
+		pair := strings.SplitN(sourceLocation, "=", 2)
+		if len(pair) == 2 && strings.TrimSpace(pair[0]) == sourceName {
+			return strings.TrimSpace(pair[1])
+		}
+	}
+	return cfg.BistroUrl
+}
+
 // Tests if the application runs in a test context
 func isTesting() bool {
 	for _, arg := range os.Args {
@@ -67,6 +138,12 @@ func loadTestConfig() {
 	if err != nil {
 		log.Fatal("The format is not valid", err)
 	}
+
+	err = readSecretFiles(&cfg)
+
+	if err != nil {
+		log.Fatal("Error loading a secret file ", err)
+	}
 }
 
 // load the configuration from the specified env path
@@ -83,4 +160,34 @@ func loadConfig() {
 	if err != nil {
 		log.Fatal("The .env format is not valid", err)
 	}
+
+	err = readSecretFiles(&cfg)
+
+	if err != nil {
+		log.Fatal("Error loading a secret file ", err)
+	}
+}
+
+// replaces credentials with the content of their secret files, e.g. docker secrets
+// a secret file takes precedence over the credential itself
+func readSecretFiles(cfg *Config) error {
+	secrets := []struct {
+		file  string
+		value *string
+	}{
+		{cfg.HttpAuthUserFile, &cfg.HttpAuthUser},
+		{cfg.HttpAuthPasswordFile, &cfg.HttpAuthPassword},
+	}
+
+	for _, secret := range secrets {
+		if secret.file == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(secret.file)
+		if err != nil {
+			return fmt.Errorf("reading the secret file %s failed: %w", secret.file, err)
+		}
+		*secret.value = strings.TrimSpace(string(content))
+	}
+	return nil
 }
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecretFiles(t *testing.T) {
+	directory, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	t.Run("expect a secret file to replace the credential", func(t *testing.T) {
+		passwordFile := filepath.Join(directory, "password")
+		ioutil.WriteFile(passwordFile, []byte("secret\n"), 0600)
+
+		cfg := Config{HttpAuthPassword: "plain", HttpAuthPasswordFile: passwordFile}
+		if err := readSecretFiles(&cfg); err != nil || cfg.HttpAuthPassword != "secret" {
+			t.Fatalf("expected the password of the secret file but got %q: %v", cfg.HttpAuthPassword, err)
+		}
+	})
+
+	t.Run("expect the credential without a secret file", func(t *testing.T) {
+		cfg := Config{HttpAuthUser: "bistro"}
+		if err := readSecretFiles(&cfg); err != nil || cfg.HttpAuthUser != "bistro" {
+			t.Fatalf("expected the configured user but got %q: %v", cfg.HttpAuthUser, err)
+		}
+	})
+
+	t.Run("expect an error for a missing secret file", func(t *testing.T) {
+		cfg := Config{HttpAuthUserFile: filepath.Join(directory, "missing")}
+		if err := readSecretFiles(&cfg); err == nil {
+			t.Fatalf("expected an error for a missing secret file")
+		}
+	})
+}
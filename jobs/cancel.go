@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"time"
+)
+
+// Returned when a job is cancelled that has finished already
+var ErrJobNotCancellable = errors.New("only pending, running and scheduled jobs can be cancelled")
+
+// Stops the crawling of a job whose status was changed by another process, e.g. because it was cancelled
+var errJobCancelled = errors.New("the job was cancelled")
+
+// Cancels a job and returns its final state
+// Pending jobs are removed from the queue, running jobs of this process have their context cancelled,
+// which stops their fetching and persistence, and jobs with a scheduled retry are not retried
+// Jobs that other processes run stop before they persist their next week
+// The cancelled status is only written over the status that was read, so a job whose status changes meanwhile
+// is cancelled in its new status and a cancelled job is never started, finished or retried afterwards
+// Returns ErrJobNotFound or ErrJobNotCancellable if the job does not exist or has finished already
+func CancelJob(ctx context.Context, jobId string) (Job, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+		job, err := readJob(ctx, jobId)
+		if err != nil {
+			return job, err
+		}
+
+		switch job.Status {
+		case "PENDING":
+			removed, err := jobQueue.Remove(ctx, job.Key)
+			if err != nil {
+				return job, err
+			}
+			if removed {
+				return readJob(ctx, jobId)
+			}
+			// the job was dequeued in the meantime
+			continue
+		case "RUNNING":
+			// the stopped worker persisted nothing afterwards, unless it finished before it noticed the cancellation
+			if workers.cancel(job.Key) {
+				continue
+			}
+		case "RETRY_SCHEDULED":
+		default:
+			return job, fmt.Errorf("%w, the job %s is %s", ErrJobNotCancellable, jobId, job.Status)
+		}
+
+		cancelled, err := markCancelled(ctx, config.Get().JobCollectionName, job.Key, job.Status)
+		if err != nil {
+			return job, err
+		}
+		if cancelled {
+			return readJob(ctx, jobId)
+		}
+	}
+}
+
+// Marks a job as cancelled if its stored status is still the expected status
+// Returns false if the status changed in the meantime
+func markCancelled(ctx context.Context, collectionName string, jobKey string, expectedStatus string) (bool, error) {
+	update := map[string]interface{}{
+		"status":          "CANCELLED",
+		"finishedTime":    time.Now().Format(time.RFC3339),
+		"nextAttemptTime": nil,
+	}
+
+	cancelledJobs := make([]Job, 0, 1)
+	err := persister.UpdateDocumentIf(collectionName, jobKey, "status", expectedStatus, update, ctx, &cancelledJobs)
+	return len(cancelledJobs) > 0, err
+}
+
+// Tells if a running job was cancelled by another process
+func isCancelled(ctx context.Context, jobKey string) bool {
+	var job Job
+	persister.ReadDocumentIfExists(config.Get().JobCollectionName, jobKey, ctx, &job)
+	return job.Status == "CANCELLED"
+}
+
+// Reads a job from the jobs collection
+// Returns ErrJobNotFound if no job exists with the id
+func readJob(ctx context.Context, jobId string) (Job, error) {
+	var job Job
+	persister.ReadDocumentIfExists(config.Get().JobCollectionName, jobId, ctx, &job)
+	if job.Key == "" {
+		return job, fmt.Errorf("%w for jobId %s", ErrJobNotFound, jobId)
+	}
+	return job, nil
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/nu7hatch/gouuid"
+	"time"
+)
+
+// Reads the stored meals of the source at all dates the crawled meals are offered at
+// The meals of other sources are left out, so they are neither compared nor removed
+func readStoredMeals(ctx context.Context, sourceName string, crawledMeals []webcrawler.Meal) ([]webcrawler.Meal, error) {
+	mealsOfDates := make([]webcrawler.Meal, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().MealCollectionName, "date", mealDates(crawledMeals), ctx, &mealsOfDates)
+	if err != nil {
+		return nil, err
+	}
+
+	storedMeals := make([]webcrawler.Meal, 0, len(mealsOfDates))
+	for _, meal := range mealsOfDates {
+		if mealSource(meal) == sourceName {
+			storedMeals = append(storedMeals, meal)
+		}
+	}
+	return storedMeals, nil
+}
+
+// Determines the source of a stored meal
+// Meals that were stored before their source was recorded were crawled from the default source
+func mealSource(meal webcrawler.Meal) string {
+	if meal.Source == "" {
+		return webcrawler.DefaultSourceName
+	}
+	return meal.Source
+}
+
+// Compares the crawled meals with the stored meals of the same source and dates
+// Every detected change is persisted as change event and meals that are not offered anymore are removed
+// Returns the detected changes
+func recordChanges(ctx context.Context, job Job, storedMeals []webcrawler.Meal, crawledMeals []webcrawler.Meal) []webcrawler.MealChange {
+	changes := webcrawler.DetectChanges(storedMeals, crawledMeals)
+	detectedTime := time.Now().Format(time.RFC3339)
+	changeEvents := make([]persister.Identifiable, len(changes))
+	removedMealIds := make([]string, 0)
+
+	for i := range changes {
+		uid, _ := uuid.NewV4()
+		changes[i].Id = uid.String()
+		changes[i].JobId = job.Id
+		changes[i].DetectedTime = detectedTime
+		changeEvents[i] = changes[i]
+
+		if changes[i].Type == webcrawler.ChangeRemoved {
+			removedMealIds = append(removedMealIds, changes[i].MealId)
+		}
+	}
+
+	persister.PersistDocuments(config.Get().ChangeCollectionName, changeEvents, ctx)
+	persister.RemoveDocuments(config.Get().MealCollectionName, removedMealIds, ctx)
+
+	return changes
+}
+
+// Collects the distinct dates of all meals
+func mealDates(meals []webcrawler.Meal) []string {
+	dates := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, meal := range meals {
+		if !seen[meal.Date] {
+			seen[meal.Date] = true
+			dates = append(dates, meal.Date)
+		}
+	}
+	return dates
+}
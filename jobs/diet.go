@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+)
+
+// Replaces the classified diet tags of the crawled meals with the stored overrides
+func applyDietOverrides(ctx context.Context, crawledMeals []webcrawler.Meal) error {
+	overrideIds := make([]string, 0, len(crawledMeals))
+	for _, meal := range crawledMeals {
+		overrideIds = append(overrideIds, webcrawler.DietOverrideId(meal.Name))
+	}
+
+	overrides := make([]webcrawler.DietOverride, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().DietOverrideCollectionName, "_key", overrideIds, ctx, &overrides)
+	if err != nil {
+		return err
+	}
+
+	webcrawler.ApplyDietOverrides(crawledMeals, overrides)
+	return nil
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/nu7hatch/gouuid"
+	"time"
+)
+
+// Compares the fingerprints of crawled pages with the layout baseline of their source
+// A fingerprint becomes the baseline of its source and profile version if there is none yet and its page yielded meals,
+// so a page that is broken from the start never becomes the baseline
+// Every detected drift is persisted as drift event
+// Returns the detected drifts
+func detectDrifts(ctx context.Context, job Job, fingerprints []webcrawler.PageFingerprint, yieldedMeals bool) []webcrawler.LayoutDrift {
+	drifts := make([]webcrawler.LayoutDrift, 0)
+	detectedTime := time.Now().Format(time.RFC3339)
+
+	for _, fingerprint := range fingerprints {
+		var baseline webcrawler.LayoutBaseline
+		persister.ReadDocumentIfExists(config.Get().BaselineCollectionName, fingerprint.BaselineId(), ctx, &baseline)
+		if baseline.Id == "" {
+			if yieldedMeals {
+				recordBaseline(ctx, fingerprint)
+			}
+			continue
+		}
+
+		drift := webcrawler.DetectDrift(baseline.Fingerprint, fingerprint, config.Get().DriftSimilarityThreshold)
+		if drift == nil {
+			continue
+		}
+
+		uid, _ := uuid.NewV4()
+		drift.Id = uid.String()
+		drift.JobId = job.Id
+		drift.DetectedTime = detectedTime
+		drifts = append(drifts, *drift)
+		persister.PersistDocument(config.Get().DriftCollectionName, *drift, ctx)
+	}
+
+	return drifts
+}
+
+// Stores the fingerprint as the layout baseline of its source and profile version
+// Pages without any shapes did not match the profile at all and are no baseline
+func recordBaseline(ctx context.Context, fingerprint webcrawler.PageFingerprint) {
+	if len(fingerprint.Shapes) == 0 {
+		return
+	}
+
+	baseline := webcrawler.LayoutBaseline{
+		Id:          fingerprint.BaselineId(),
+		Fingerprint: fingerprint,
+		CreatedTime: time.Now().Format(time.RFC3339),
+	}
+	persister.PersistDocument(config.Get().BaselineCollectionName, baseline, ctx)
+}
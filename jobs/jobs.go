@@ -5,36 +5,85 @@ Package jobs implements a service that is able to enqueue crawler jobs.
 This jobs gets dequeued periodically and their status is persisted into the jobs document collection.
 */
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Rate-My-Bistro/crawler/config"
 	"github.com/Rate-My-Bistro/crawler/persister"
 	"github.com/Rate-My-Bistro/crawler/webcrawler"
 	"github.com/go-co-op/gocron"
 	"github.com/nu7hatch/gouuid"
 	"log"
+	"reflect"
+	"strings"
 	"time"
 )
 
 // Represents a crawler job
 type Job struct {
-	Key          string   `json:"_key,omitempty"` // unique identifier for the database
-	Id           string   `json:"id,omitempty"`   // uuid that unique identifies the job
-	DateToParse  string   `json:"dateToParse"`    // The date which the parser should parse / has parsed.
-	Status       string   `json:"status"`         // PENDING | RUNNING |  SUCCESS | FAILURE
-	EnqueuedTime string   `json:"enqueuedTime"`   // time the job was enqueued
-	StartedTime  string   `json:"startedTime"`    // the time the job has started the parsing
-	FinishedTime string   `json:"finishedTime"`   // the time the job has finished the parsing process
-	Additional   []string `json:"additional"`     // optional information to keep near to the job (e.g. error messages)
+	Key             string   `json:"_key,omitempty"`            // unique identifier for the database
+	Id              string   `json:"id,omitempty"`              // uuid that unique identifies the job
+	Source          string   `json:"source"`                    // name of the menu source the job crawls
+	DateToParse     string   `json:"dateToParse"`               // The date which the parser should parse / has parsed.
+	LastDateToParse string   `json:"lastDateToParse,omitempty"` // the last date of a range job, blank if the job parses a single week
+	Status          string   `json:"status"`                    // PENDING | RUNNING |  SUCCESS | DEGRADED | UNCHANGED | FAILURE | RETRY_SCHEDULED | DEAD_LETTER | CANCELLED
+	EnqueuedTime    string   `json:"enqueuedTime"`              // time the job was enqueued
+	StartedTime     string   `json:"startedTime"`               // the time the job has started the parsing
+	FinishedTime    string   `json:"finishedTime"`              // the time the job has finished the parsing process
+	Additional      []string `json:"additional"`                // optional information to keep near to the job (e.g. error messages)
+	CrawledWeeks    int      `json:"crawledWeeks,omitempty"`    // the position of the last week that was crawled and persisted
+	TotalWeeks      int      `json:"totalWeeks,omitempty"`      // the number of weeks the job crawls
+	Recoveries      int      `json:"recoveries,omitempty"`      // how often the lease of the job expired before it finished
+	NextAttemptTime string   `json:"nextAttemptTime,omitempty"` // the time a job with a scheduled retry is enqueued again
+	FailedAttempts  int      `json:"failedAttempts"`            // the failed attempts since the job was enqueued or requeued
+
+	RetryPolicy RetryPolicy  `json:"retryPolicy"`        // how often and when the job is retried after a failure
+	Attempts    []JobAttempt `json:"attempts,omitempty"` // every finished attempt with its error
+
+	ParseReport *webcrawler.ParseReport  `json:"parseReport,omitempty"` // skipped nodes, warnings and archived snapshots of the parsing
+	Changes     []webcrawler.MealChange  `json:"changes,omitempty"`     // meals that were added, removed or changed since the previous crawl
+	Drifts      []webcrawler.LayoutDrift `json:"drifts,omitempty"`      // crawled pages whose layout deviates from the baseline
 }
 
-// Holds all jobs in memory as a queue
-var JobQueue = make([]Job, 0)
+// Holds the pending jobs, the queue is a view over the jobs collection
+// A memory queue is rebuilt from the pending jobs of the collection at startup
+var jobQueue Queue
+
+// Runs the jobs of the queue
+var workers *workerPool
 
-// Crates a new scheduler for the configured interval
+// Keeps the validators of the fetched pages in their collection,
+// creates the configured queue, rebuilds it from the jobs collection and starts the workers
+// The workers are woken by new jobs and, if a tick is configured, poll the queue on every tick of a scheduler,
+// which picks up jobs that other processes enqueued to a database queue
+// The lease timeout must exceed the job timeout, otherwise jobs would be reclaimed while they are running
 func init() {
+	if leaseTimeout() <= jobTimeout() {
+		log.Fatalf("The job lease timeout of %s must exceed the job timeout of %s", leaseTimeout(), jobTimeout())
+	}
+
+	webcrawler.UsePageValidatorStore(newValidatorStore(config.Get().ValidatorCollectionName))
+
+	queue, err := newConfiguredQueue(config.Get())
+	if err != nil {
+		log.Fatal("Failed to create the job queue ", err)
+	}
+	jobQueue = queue
+	if err := recoverJobQueue(context.Background()); err != nil {
+		log.Fatal("Failed to recover the job queue ", err)
+	}
+
+	workers = newWorkerPool(jobQueue, int(config.Get().JobWorkers), int(config.Get().JobWorkersPerSource))
+	workers.start()
+
 	schedulerTick := config.Get().JobSchedulerTickInSeconds
+	if schedulerTick == 0 {
+		return
+	}
 
 	s1 := gocron.NewScheduler(time.UTC)
-	_, err := s1.Every(schedulerTick).Seconds().Do(processNextJob)
+	_, err = s1.Every(schedulerTick).Seconds().Do(workers.notify)
 	s1.StartAsync()
 
 	if err != nil {
@@ -42,70 +91,289 @@ func init() {
 	}
 }
 
-// Gets called on every tick of the scheduler
-// It dequeues the head of the queue and start the parsing process.
+// Gets called by a worker for every dequeued job, which the queue marked as running
+// It starts the parsing process of the job.
 // Every job status change is persisted to the job collection.
-func processNextJob() {
-	if len(JobQueue) <= 0 {
+// The job fails with a timeout if it exceeds the configured deadline.
+// A job that is interrupted because the workers are drained keeps its running status,
+// a cancelled job gets its final status from the cancellation.
+func processJob(ctx context.Context, nextJob Job) {
+	ctx = webcrawler.TrackFetchedPages(ctx)
+	jobCtx, cancel := withJobDeadline(ctx)
+	defer cancel()
+
+	// start the meal crawling and store every week in the database as soon as it is parsed
+	log.Println("Start crawling meals of source " + nextJob.Source + " for " + describeDates(nextJob))
+	checkedFingerprints := 0
+	report, err := crawl(jobCtx, nextJob, func(ctx context.Context, batch webcrawler.WeekBatch) error {
+		if isCancelled(ctx, nextJob.Key) {
+			return errJobCancelled
+		}
+		if err := persistWeek(ctx, &nextJob, batch); err != nil {
+			return err
+		}
+		checkedFingerprints += len(batch.Report.Fingerprints)
+		return ctx.Err()
+	})
+	nextJob.ParseReport = &report
+	if ctx.Err() != nil || errors.Is(err, errJobCancelled) {
+		// the weeks that were fetched but not persisted must not be skipped as unchanged by the next job
+		webcrawler.ForgetFetchedPageValidators(ctx)
+		log.Println("Stopped crawling meals for " + describeDates(nextJob))
+		return
+	}
+	if errors.Is(err, webcrawler.ErrNotModified) {
+		jobUnchangedFinished(ctx, nextJob)
+		log.Println("The menu is unchanged for " + describeDates(nextJob))
 		return
 	}
-
-	// dequeue the next job and prepare it
-	nextJob := DequeueJob()
-	nextJob.StartedTime = time.Now().Format(time.RFC3339)
-	nextJob.Status = "RUNNING"
-	persister.PersistDocument(config.Get().JobCollectionName, nextJob)
-
-	// start the meal crawling and store the result in the database
-	log.Println("Start crawling meals for date " + nextJob.DateToParse)
-	crawledMeals, err := webcrawler.CrawlAtDate(config.Get().BistroUrl, nextJob.DateToParse)
 	if err != nil {
-		jobFailureFinished(nextJob, err)
+		// a page that cannot be parsed any more may have changed its layout, which no retry fixes
+		drifts := detectDrifts(ctx, nextJob, report.Fingerprints[checkedFingerprints:], false)
+		if len(drifts) > 0 {
+			nextJob.Drifts = append(nextJob.Drifts, drifts...)
+			jobDegradedFinished(ctx, nextJob, err)
+			return
+		}
+		jobFailureFinished(ctx, nextJob, describeFailure(jobCtx, err))
+		return
+	}
+
+	// the persister logs its errors, so an exceeded deadline is only noticed by the context
+	if err := jobCtx.Err(); err != nil {
+		jobFailureFinished(ctx, nextJob, describeFailure(jobCtx, err))
 		return
 	}
-	persister.PersistDocuments(config.Get().MealCollectionName, ToIdentifiables(crawledMeals))
 
 	// mark the job as finished successful
-	jobSuccessFinished(nextJob)
-	log.Println("Finished crawling meals for date " + nextJob.DateToParse)
+	jobSuccessFinished(ctx, nextJob)
+	log.Println("Finished crawling meals for " + describeDates(nextJob))
+}
+
+// Derives a context that is cancelled once the configured job timeout is exceeded
+func withJobDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, jobTimeout())
+}
+
+// The time a single job may take at most
+func jobTimeout() time.Duration {
+	return time.Duration(config.Get().JobTimeoutInSeconds) * time.Second
+}
+
+// Adds the timeout as reason to the error of a job whose deadline was exceeded
+func describeFailure(jobCtx context.Context, err error) error {
+	if jobCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timeout: the job exceeded its deadline of %s: %w", jobTimeout(), err)
+	}
+	return err
+}
+
+// Crawls the meals the job targets and hands every crawled week to the handler
+// Range jobs crawl every week of their range, all other jobs the week of their date
+func crawl(ctx context.Context, job Job, handler webcrawler.MealHandler) (webcrawler.ParseReport, error) {
+	location := config.Get().SourceLocation(job.Source)
+	if job.LastDateToParse != "" {
+		return webcrawler.StreamSourceRange(ctx, job.Source, location, job.DateToParse, job.LastDateToParse, handler)
+	}
+	return webcrawler.StreamSource(ctx, job.Source, location, job.DateToParse, handler)
+}
+
+// Stores the meals and days of a crawled week and records their changes and layout drifts at the job
+// The job is persisted with its progress afterwards, so the progress of long range jobs can be followed
+// Returns errJobCancelled if the job is no longer running, e.g. because another process cancelled it
+func persistWeek(ctx context.Context, job *Job, batch webcrawler.WeekBatch) error {
+	crawledMeals := batch.Meals
+
+	// manual diet tags take priority over the classified ones
+	if err := applyDietOverrides(ctx, crawledMeals); err != nil {
+		job.Additional = append(job.Additional, "reading the diet overrides failed: "+err.Error())
+	}
+
+	// keep the identity of edited meals and compare with the stored meals before they get overwritten
+	storedMeals, err := readStoredMeals(ctx, job.Source, crawledMeals)
+	if err != nil {
+		job.Additional = append(job.Additional, "reading the stored meals failed: "+err.Error())
+	} else {
+		crawledMeals = webcrawler.MatchIdentities(storedMeals, crawledMeals)
+		job.Changes = append(job.Changes, recordChanges(ctx, *job, storedMeals, crawledMeals)...)
+	}
+	// meals are replaced, so the nutrition, images or diet tags a page does not list anymore are not kept
+	persister.ReplaceDocuments(config.Get().MealCollectionName, ToIdentifiables(crawledMeals), ctx)
+	persister.PersistDocuments(config.Get().DayCollectionName, dayIdentifiables(batch.Report.Days), ctx)
+	job.Drifts = append(job.Drifts, detectDrifts(ctx, *job, batch.Report.Fingerprints, len(batch.Meals) > 0)...)
+
+	job.CrawledWeeks, job.TotalWeeks = batch.Week, batch.Weeks
+	running, err := updateJobIf(ctx, config.Get().JobCollectionName, *job, "RUNNING")
+	if err != nil {
+		log.Println("Persisting the progress of the job " + job.Id + " failed: " + err.Error())
+		return nil
+	}
+	if !running {
+		return errJobCancelled
+	}
+	return nil
+}
+
+// Describes the dates a job parses for log messages
+func describeDates(job Job) string {
+	if job.LastDateToParse != "" {
+		return "dates " + job.DateToParse + " to " + job.LastDateToParse
+	}
+	return "date " + job.DateToParse
 }
 
-func jobSuccessFinished(job Job) {
+// a job that crawled pages with a drifted layout is only degraded, as its meals may be incomplete
+func jobSuccessFinished(ctx context.Context, job Job) {
 	job.FinishedTime = time.Now().Format(time.RFC3339)
 	job.Status = "SUCCESS"
-	persister.PersistDocument(config.Get().JobCollectionName, job)
+	if len(job.Drifts) > 0 {
+		job.Status = "DEGRADED"
+	}
+	recordAttempt(&job, nil)
+	finishJob(ctx, job)
+}
+
+// a job whose page failed to parse because its layout drifted is degraded instead of failed, as retrying it is pointless
+// the validators of its pages are forgotten, so a fixed parser parses the pages again with the next job
+func jobDegradedFinished(ctx context.Context, job Job, err error) {
+	webcrawler.ForgetFetchedPageValidators(ctx)
+
+	job.FinishedTime = time.Now().Format(time.RFC3339)
+	job.Status = "DEGRADED"
+	job.Additional = append(job.Additional, err.Error())
+	recordAttempt(&job, err)
+	finishJob(ctx, job)
 }
 
-func jobFailureFinished(job Job, err error) {
+// a job whose pages were not modified since the last crawl neither parses nor persists any meals
+func jobUnchangedFinished(ctx context.Context, job Job) {
 	job.FinishedTime = time.Now().Format(time.RFC3339)
-	job.Status = "FAILURE"
-	job.Additional = []string{err.Error()}
-	persister.PersistDocument(config.Get().JobCollectionName, job)
+	job.Status = "UNCHANGED"
+	recordAttempt(&job, nil)
+	finishJob(ctx, job)
+}
+
+// the validators of the pages the job fetched are forgotten, so the pages are parsed again by the next job
+// the retry policy of the job decides if it is failed, dead-lettered or retried later
+func jobFailureFinished(ctx context.Context, job Job, err error) {
+	webcrawler.ForgetFetchedPageValidators(ctx)
+
+	now := time.Now()
+	job.FinishedTime = now.Format(time.RFC3339)
+	job.Additional = append(job.Additional, err.Error())
+	scheduleRetry(&job, err, now)
+	recordAttempt(&job, err)
+	finishJob(ctx, job)
+}
+
+// Persists the final status of a running job with a deadline of its own, so it is stored even if the job timed out
+// A job that was cancelled while it finished keeps its cancelled status
+func finishJob(ctx context.Context, job Job) {
+	statusCtx, cancel := withJobDeadline(ctx)
+	defer cancel()
+
+	finished, err := updateJobIf(statusCtx, config.Get().JobCollectionName, job, "RUNNING")
+	if err != nil {
+		log.Println("Persisting the status of the job " + job.Id + " failed: " + err.Error())
+	} else if !finished {
+		log.Println("The job " + job.Id + " is no longer running, its status " + job.Status + " is dropped")
+	}
+}
+
+// Persists a job if its stored status is still the expected status
+// Every status change of a stored job goes through this check, so a cancelled job stays cancelled
+// Returns false if the status changed in the meantime
+func updateJobIf(ctx context.Context, collectionName string, job Job, expectedStatus string) (bool, error) {
+	update, err := jobUpdate(job)
+	if err != nil {
+		return false, err
+	}
+
+	updatedJobs := make([]Job, 0, 1)
+	err = persister.UpdateDocumentIf(collectionName, job.Key, "status", expectedStatus, update, ctx, &updatedJobs)
+	return len(updatedJobs) > 0, err
+}
+
+// Converts a job into the attributes that update its document
+// Empty attributes are left out of a job document, they are set to null so the update clears their stored values
+func jobUpdate(job Job) (map[string]interface{}, error) {
+	document, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	update := make(map[string]interface{})
+	if err := json.Unmarshal(document, &update); err != nil {
+		return nil, err
+	}
+
+	jobType := reflect.TypeOf(job)
+	for i := 0; i < jobType.NumField(); i++ {
+		attribute := strings.Split(jobType.Field(i).Tag.Get("json"), ",")[0]
+		if _, exists := update[attribute]; !exists {
+			update[attribute] = nil
+		}
+	}
+	delete(update, "_key")
+	return update, nil
 }
 
 // Enqueues a new parser job for a specific date at the end of the queue
+// The job crawls the default menu source
 // Returns the id of the created job
-func EnqueueJob(dateToParse string) string {
+func EnqueueJob(ctx context.Context, dateToParse string) string {
+	identifier, _ := EnqueueSourceJob(ctx, webcrawler.DefaultSourceName, dateToParse)
+	return identifier
+}
+
+// Enqueues a new parser job for a specific date and menu source at the end of the queue
+// Returns the id of the created job or an error if the menu source is unknown
+func EnqueueSourceJob(ctx context.Context, sourceName string, dateToParse string) (string, error) {
+	return EnqueueRangeJob(ctx, sourceName, dateToParse, "")
+}
+
+// Enqueues a single parser job for all dates between two dates (inclusive) at the end of the queue
+// Leave the last date blank to parse the whole week of the first date
+// Returns the id of the created job or an error if the menu source is unknown
+func EnqueueRangeJob(ctx context.Context, sourceName string, dateToParse string, lastDateToParse string) (string, error) {
+	if _, err := webcrawler.GetSource(sourceName); err != nil {
+		return "", err
+	}
+
 	uid, _ := uuid.NewV4()
 	identifier := uid.String()
 	newJob := Job{
-		Key:          identifier,
-		Id:           identifier,
-		Status:       "PENDING",
-		EnqueuedTime: time.Now().Format(time.RFC3339),
-		DateToParse:  dateToParse,
-	}
-	JobQueue = append(JobQueue, newJob)
-	persister.PersistDocument(config.Get().JobCollectionName, newJob)
-	return identifier
+		Key:             identifier,
+		Id:              identifier,
+		Source:          sourceName,
+		Status:          "PENDING",
+		EnqueuedTime:    time.Now().Format(time.RFC3339),
+		DateToParse:     dateToParse,
+		LastDateToParse: lastDateToParse,
+		RetryPolicy:     defaultRetryPolicy(),
+	}
+	if err := jobQueue.Enqueue(ctx, newJob); err != nil {
+		return "", err
+	}
+	workers.notify()
+	return identifier, nil
 }
 
 // Dequeues the head of the queue.
-// This removes the dequeued item
-func DequeueJob() (nextJob Job) {
-	nextJob = JobQueue[0]
-	JobQueue = JobQueue[1:] // Discard top element
-	return nextJob
+// This removes the dequeued item and marks it as running
+// Returns false if the queue is empty
+func DequeueJob(ctx context.Context) (Job, bool, error) {
+	return jobQueue.Dequeue(ctx, nil)
+}
+
+// Stops starting new jobs and waits until the running jobs are finished or the context is done
+// Returns an error if running jobs had to be interrupted, they are enqueued again after a restart
+func Drain(ctx context.Context) error {
+	return workers.drain(ctx)
+}
+
+// Returns the pending jobs, the next job first
+func PendingJobs(ctx context.Context) ([]Job, error) {
+	return jobQueue.List(ctx)
 }
 
 // Identifiable interface implantation for the struct job
@@ -122,7 +390,16 @@ func ToIdentifiables(meals []webcrawler.Meal) []persister.Identifiable {
 	return identifiables
 }
 
-// Removes all entries from the job queue
-func RemoveAllJobs() {
-	JobQueue = make([]Job, 0)
+// Converts the crawled days into persistable documents
+func dayIdentifiables(days []webcrawler.Day) []persister.Identifiable {
+	identifiables := make([]persister.Identifiable, len(days))
+	for i := range days {
+		identifiables[i] = days[i]
+	}
+	return identifiables
+}
+
+// Removes all entries from the job queue and their pending jobs from the jobs collection
+func RemoveAllJobs(ctx context.Context) error {
+	return jobQueue.Clear(ctx)
 }
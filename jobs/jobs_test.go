@@ -1,47 +1,443 @@
 package jobs
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAddJobsToQueue(t *testing.T) {
+	ctx := context.Background()
+	// a queue of its own, the workers would take the jobs of the package queue
+	queue := NewMemoryQueue(config.Get().JobCollectionName)
+	defer queue.Clear(ctx)
+
 	t.Run("when adding 3 jobs to queue they should be present", func(t *testing.T) {
-		EnqueueJob("2020-08-03")
-		EnqueueJob("2020-07-03")
-		EnqueueJob("2020-06-03")
+		for i, date := range []string{"2020-08-03", "2020-07-03", "2020-06-03"} {
+			queue.Enqueue(ctx, Job{Key: fmt.Sprintf("queued-%d", i), Source: "cgm", DateToParse: date, Status: "PENDING"})
+		}
+
+		if pendingJobs, _ := queue.List(ctx); len(pendingJobs) != 3 {
+			t.Fatalf("The queue size should be 3 but is %d", len(pendingJobs))
+		}
+
+		for i, date := range []string{"2020-08-03", "2020-07-03", "2020-06-03"} {
+			job, found, err := queue.Dequeue(ctx, nil)
+			if err != nil || !found || job.DateToParse != date {
+				t.Fatalf("The dequeued job %d should parse the date %s but got %q (%v)", i+1, date, job.DateToParse, err)
+			}
+			if job.Status != "RUNNING" {
+				t.Fatalf("The dequeued job should be running but is %s", job.Status)
+			}
+			if pendingJobs, _ := queue.List(ctx); len(pendingJobs) != 2-i {
+				t.Fatalf("The queue size should be %d but is %d", 2-i, len(pendingJobs))
+			}
+		}
+
+		if _, found, _ := queue.Dequeue(ctx, nil); found {
+			t.Fatalf("The empty queue should not return a job")
+		}
+	})
+
+	t.Run("when a source is excluded its jobs should be skipped", func(t *testing.T) {
+		queue.Enqueue(ctx, Job{Key: "queued-cgm", Source: "cgm", Status: "PENDING"})
+		queue.Enqueue(ctx, Job{Key: "queued-other", Source: "other", Status: "PENDING"})
+
+		job, found, _ := queue.Dequeue(ctx, []string{"cgm"})
+		if !found || job.Source != "other" {
+			t.Fatalf("The job of the other source should be dequeued but got %q", job.Source)
+		}
+		if job, _, _ = queue.Dequeue(ctx, nil); job.Source != "cgm" {
+			t.Fatalf("The skipped job should stay at the head of the queue but got %q", job.Source)
+		}
+	})
+
+	persister.RemoveDocuments(config.Get().JobCollectionName, []string{"queued-0", "queued-1", "queued-2", "queued-cgm", "queued-other"}, ctx)
+}
+
+func TestWorkerPoolCapacity(t *testing.T) {
+	pool := newWorkerPool(NewMemoryQueue(config.Get().JobCollectionName), 3, 2)
+	pool.running = map[string]int{"cgm": 2}
+	pool.total = 2
+
+	t.Run("expect sources at their limit to be busy", func(t *testing.T) {
+		busySources, free := pool.capacity()
+		if !free || len(busySources) != 1 || busySources[0] != "cgm" {
+			t.Fatalf("expected a free worker and the busy source cgm but got %v, %v", free, busySources)
+		}
+	})
+
+	t.Run("expect no free worker once all workers run", func(t *testing.T) {
+		pool.running["other"], pool.total = 1, 3
+		if _, free := pool.capacity(); free {
+			t.Fatalf("expected all workers to be busy")
+		}
+	})
+
+	t.Run("expect a drained pool without jobs to stop at once", func(t *testing.T) {
+		idlePool := newWorkerPool(NewMemoryQueue(config.Get().JobCollectionName), 1, 0)
+		idlePool.start()
+		if err := idlePool.drain(context.Background()); err != nil {
+			t.Fatalf("expected the pool to drain without interruption but got %v", err)
+		}
+	})
+}
+
+func TestConcurrentMemoryQueue(t *testing.T) {
+	t.Run("expect every job to be dequeued exactly once", func(t *testing.T) {
+		ctx := context.Background()
+		queue := NewMemoryQueue(config.Get().JobCollectionName)
+
+		var waitGroup sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			waitGroup.Add(1)
+			go func(i int) {
+				defer waitGroup.Done()
+				queue.Enqueue(ctx, Job{Key: fmt.Sprintf("concurrent-%d", i), Status: "PENDING"})
+			}(i)
+		}
+		waitGroup.Wait()
+
+		dequeued := make(chan string, 20)
+		for i := 0; i < 20; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				if job, found, _ := queue.Dequeue(ctx, nil); found {
+					dequeued <- job.Key
+				}
+			}()
+		}
+		waitGroup.Wait()
+		close(dequeued)
+
+		seen := make(map[string]bool)
+		for key := range dequeued {
+			if seen[key] {
+				t.Fatalf("The job %s was dequeued twice", key)
+			}
+			seen[key] = true
+		}
+		if len(seen) != 20 {
+			t.Fatalf("All 20 jobs should be dequeued but got %d", len(seen))
+		}
+
+		keys := make([]string, 0, len(seen))
+		for key := range seen {
+			keys = append(keys, key)
+		}
+		persister.RemoveDocuments(config.Get().JobCollectionName, keys, ctx)
+	})
+}
+
+func TestDescribeFailure(t *testing.T) {
+	t.Run("expect an exceeded deadline to be reported as timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		<-ctx.Done()
+
+		err := describeFailure(ctx, ctx.Err())
+		if !strings.HasPrefix(err.Error(), "timeout") || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a timeout error but got %v", err)
+		}
+	})
+
+	t.Run("expect other errors to be kept", func(t *testing.T) {
+		err := errors.New("parsing failed")
+		if got := describeFailure(context.Background(), err); got != err {
+			t.Fatalf("expected the original error but got %v", got)
+		}
+	})
+}
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Now()
+
+	t.Run("expect a job within the lease timeout to keep its lease", func(t *testing.T) {
+		job := Job{Status: "RUNNING", StartedTime: now.Add(-jobTimeout()).Format(time.RFC3339)}
+		if leaseExpired(job, now) {
+			t.Fatalf("expected the lease to be valid for a job started at %s", job.StartedTime)
+		}
+	})
+
+	t.Run("expect a job beyond the lease timeout to lose its lease", func(t *testing.T) {
+		job := Job{Status: "RUNNING", StartedTime: now.Add(-leaseTimeout()).Format(time.RFC3339)}
+		if !leaseExpired(job, now) {
+			t.Fatalf("expected the lease to be expired for a job started at %s", job.StartedTime)
+		}
+	})
+
+	t.Run("expect a job without start time to have no lease", func(t *testing.T) {
+		if !leaseExpired(Job{Status: "RUNNING"}, now) {
+			t.Fatalf("expected no lease for a job without start time")
+		}
+	})
+}
+
+func TestExpireLease(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, DelayInSeconds: 30, MaxDelayInSeconds: 100, RetryableErrorClasses: []string{ErrorClassTimeout}}
+	now := time.Now()
+
+	t.Run("expect an expired lease to schedule a retry", func(t *testing.T) {
+		job := Job{Status: "RUNNING", RetryPolicy: policy}
+		expireLease(&job, now)
+		if job.Status != "RETRY_SCHEDULED" || len(job.Attempts) != 1 || job.Attempts[0].ErrorClass != ErrorClassTimeout {
+			t.Fatalf("expected a scheduled retry after a timed out attempt but got %s with %+v", job.Status, job.Attempts)
+		}
+	})
+
+	t.Run("expect a job whose lease expired too often to be dead-lettered", func(t *testing.T) {
+		job := Job{Status: "RUNNING", RetryPolicy: policy, FailedAttempts: 2, Recoveries: 2}
+		expireLease(&job, now)
+		if job.Status != "DEAD_LETTER" || job.Recoveries != 3 {
+			t.Fatalf("expected a dead-lettered job after 3 expired leases but got %s after %d", job.Status, job.Recoveries)
+		}
+	})
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, DelayInSeconds: 30, MaxDelayInSeconds: 100, RetryableErrorClasses: []string{ErrorClassNetwork, ErrorClassServer}}
 
-		if len(JobQueue) != 3 {
-			t.Fatalf("The queue size should be 3 but is %q", len(JobQueue))
+	t.Run("expect the delay to double up to the max delay", func(t *testing.T) {
+		for failedAttempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: 60 * time.Second, 3: 100 * time.Second, 10: 100 * time.Second} {
+			if got := policy.delay(failedAttempts); got != want {
+				t.Fatalf("expected a delay of %s after %d failed attempts but got %s", want, failedAttempts, got)
+			}
+		}
+	})
+
+	t.Run("expect errors to be classified", func(t *testing.T) {
+		tests := map[error]string{
+			fmt.Errorf("crawling failed: %w", context.DeadlineExceeded):     ErrorClassTimeout,
+			&webcrawler.HttpStatusError{StatusCode: 503, Status: "503"}:     ErrorClassServer,
+			&webcrawler.HttpStatusError{StatusCode: 429, Status: "429"}:     ErrorClassServer,
+			&webcrawler.HttpStatusError{StatusCode: 404, Status: "404"}:     ErrorClassClient,
+			&webcrawler.RobotsError{Url: "http://localhost"}:                ErrorClassRobots,
+			&net.OpError{Op: "dial", Err: errors.New("connection refused")}: ErrorClassNetwork,
+			errors.New("something else"):                                    ErrorClassOther,
+		}
+		for err, want := range tests {
+			if got := classifyError(err); got != want {
+				t.Fatalf("expected the class %s for '%v' but got %s", want, err, got)
+			}
 		}
+	})
 
-		job1 := DequeueJob()
-		if job1.DateToParse != "2020-08-03" {
-			t.Fatalf("The first dequeued job should parse the date 2020-08-03 but got %q", job1.DateToParse)
+	t.Run("expect retryable errors to be retried until the attempts are used up", func(t *testing.T) {
+		now := time.Now()
+		job := Job{RetryPolicy: policy}
+		networkErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
 
+		scheduleRetry(&job, networkErr, now)
+		if job.Status != "RETRY_SCHEDULED" || job.NextAttemptTime != now.Add(30*time.Second).Format(time.RFC3339) {
+			t.Fatalf("expected a retry in 30s but got %s at %s", job.Status, job.NextAttemptTime)
+		}
+		scheduleRetry(&job, networkErr, now)
+		scheduleRetry(&job, networkErr, now)
+		if job.Status != "DEAD_LETTER" {
+			t.Fatalf("expected the job to be dead-lettered after 3 attempts but got %s", job.Status)
 		}
-		if len(JobQueue) != 2 {
-			t.Fatalf("The queue size should be 2 but is %q", len(JobQueue))
+	})
 
+	t.Run("expect other errors to fail the job at once", func(t *testing.T) {
+		job := Job{RetryPolicy: policy}
+		scheduleRetry(&job, &webcrawler.HttpStatusError{StatusCode: 404, Status: "404"}, time.Now())
+		if job.Status != "FAILURE" {
+			t.Fatalf("expected the job to fail but got %s", job.Status)
 		}
+	})
+}
+
+func TestCancelJob(t *testing.T) {
+	ctx := context.Background()
 
-		job2 := DequeueJob()
-		if job2.DateToParse != "2020-07-03" {
-			t.Fatalf("The first dequeued job should parse the date 2020-08-03 but got %q", job2.DateToParse)
+	t.Run("expect a pending job to be removed from the memory queue", func(t *testing.T) {
+		queue := NewMemoryQueue(config.Get().JobCollectionName)
+		queue.Enqueue(ctx, Job{Key: "cancel-1", Status: "PENDING"})
+		queue.Enqueue(ctx, Job{Key: "cancel-2", Status: "PENDING"})
+		defer persister.RemoveDocuments(config.Get().JobCollectionName, []string{"cancel-1", "cancel-2"}, ctx)
+
+		if removed, err := queue.Remove(ctx, "cancel-1"); !removed || err != nil {
+			t.Fatalf("expected the job cancel-1 to be removed but got %v, %v", removed, err)
+		}
+		if removed, _ := queue.Remove(ctx, "cancel-1"); removed {
+			t.Fatalf("expected the job cancel-1 to be removed only once")
+		}
+		if pendingJobs, _ := queue.List(ctx); len(pendingJobs) != 1 || pendingJobs[0].Key != "cancel-2" {
+			t.Fatalf("expected only the job cancel-2 to be pending but got %v", pendingJobs)
+		}
+	})
 
+	t.Run("expect a pool to cancel only the jobs it runs", func(t *testing.T) {
+		pool := newWorkerPool(NewMemoryQueue(config.Get().JobCollectionName), 1, 0)
+		if pool.cancel("unknown") {
+			t.Fatalf("expected no job to be cancelled by an idle pool")
 		}
-		if len(JobQueue) != 1 {
-			t.Fatalf("The queue size should be 1 but is %q", len(JobQueue))
+	})
 
+	t.Run("expect a pending job to be cancelled and gone from the pending jobs", func(t *testing.T) {
+		// the pool is not started, so the job stays pending
+		queue := NewMemoryQueue(config.Get().JobCollectionName)
+		useQueue(t, queue, newWorkerPool(queue, 1, 0))
+		jobId, _ := EnqueueSourceJob(ctx, "cgm", "2020-06-08")
+		defer persister.RemoveDocuments(config.Get().JobCollectionName, []string{jobId}, ctx)
+
+		job, err := CancelJob(ctx, jobId)
+		if err != nil || job.Status != "CANCELLED" || job.FinishedTime == "" {
+			t.Fatalf("expected the job to be cancelled but got %+v, %v", job, err)
+		}
+		if pendingJobs, _ := PendingJobs(ctx); len(pendingJobs) != 0 {
+			t.Fatalf("expected no pending job but got %v", pendingJobs)
 		}
+		if _, err := CancelJob(ctx, jobId); !errors.Is(err, ErrJobNotCancellable) {
+			t.Fatalf("expected a cancelled job not to be cancellable but got %v", err)
+		}
+	})
 
-		job3 := DequeueJob()
-		if job3.DateToParse != "2020-06-03" {
-			t.Fatalf("The first dequeued job should parse the date 2020-08-03 but got %q", job3.DateToParse)
+	t.Run("expect a running job to stop fetching and to persist nothing once it is cancelled", func(t *testing.T) {
+		source := blockingSource{fetching: make(chan struct{}), cancelled: make(chan struct{})}
+		webcrawler.RegisterSource("cancel-test", source)
+		queue := NewMemoryQueue(config.Get().JobCollectionName)
+		pool := newWorkerPool(queue, 1, 0)
+		useQueue(t, queue, pool)
+		pool.start()
+		defer pool.drain(ctx)
 
+		jobId, _ := EnqueueSourceJob(ctx, "cancel-test", "2020-06-08")
+		defer persister.RemoveDocuments(config.Get().JobCollectionName, []string{jobId}, ctx)
+		select {
+		case <-source.fetching:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the job to be started")
 		}
-		if len(JobQueue) != 0 {
-			t.Fatalf("The queue size should be 0 but is %q", len(JobQueue))
 
+		job, err := CancelJob(ctx, jobId)
+		if err != nil || job.Status != "CANCELLED" {
+			t.Fatalf("expected the job to be cancelled but got %+v, %v", job, err)
+		}
+		select {
+		case <-source.cancelled:
+		default:
+			t.Fatalf("expected the context of the fetch to be cancelled")
+		}
+		if job.StartedTime == "" || job.CrawledWeeks != 0 || job.ParseReport != nil || len(job.Attempts) != 0 {
+			t.Fatalf("expected nothing to be persisted after the start of the job but got %+v", job)
+		}
+	})
+}
+
+// Replaces the queue and workers of the package until the test is finished,
+// so the workers of the package never take the jobs of the test
+func useQueue(t *testing.T, queue Queue, pool *workerPool) {
+	previousQueue, previousWorkers := jobQueue, workers
+	jobQueue, workers = queue, pool
+	t.Cleanup(func() {
+		jobQueue, workers = previousQueue, previousWorkers
+	})
+}
+
+// Blocks every fetch until its context is done, so a job keeps running until it is cancelled
+type blockingSource struct {
+	fetching  chan struct{}
+	cancelled chan struct{}
+}
+
+func (source blockingSource) FetchWeek(ctx context.Context, location string, date string) (io.Reader, error) {
+	close(source.fetching)
+	<-ctx.Done()
+	close(source.cancelled)
+	return nil, ctx.Err()
+}
+
+func (source blockingSource) ParseWeek(reader io.Reader) ([]webcrawler.Meal, webcrawler.ParseReport, error) {
+	return nil, webcrawler.ParseReport{}, nil
+}
+
+func TestRecordChanges(t *testing.T) {
+	ctx := context.Background()
+	cgmMeal := webcrawler.Meal{Source: "cgm", Date: "2020-06-08", Name: "Suppe", Price: webcrawler.EuroCents(310)}
+	cgmMeal.Id = webcrawler.MealId(cgmMeal.Source, cgmMeal.Date, cgmMeal.Name)
+	otherMeal := webcrawler.Meal{Source: "other", Date: cgmMeal.Date, Name: "Nudeln", Price: webcrawler.EuroCents(420)}
+	otherMeal.Id = webcrawler.MealId(otherMeal.Source, otherMeal.Date, otherMeal.Name)
+	persister.PersistDocuments(config.Get().MealCollectionName, ToIdentifiables([]webcrawler.Meal{cgmMeal, otherMeal}), ctx)
+	defer persister.RemoveDocuments(config.Get().MealCollectionName, []string{cgmMeal.Id, otherMeal.Id}, ctx)
+
+	t.Run("expect a crawl to compare and remove only the meals of its source", func(t *testing.T) {
+		crawledMeal := webcrawler.Meal{Source: "cgm", Date: cgmMeal.Date, Name: "Pizza", Price: webcrawler.EuroCents(530)}
+		crawledMeal.Id = webcrawler.MealId(crawledMeal.Source, crawledMeal.Date, crawledMeal.Name)
+
+		storedMeals, err := readStoredMeals(ctx, "cgm", []webcrawler.Meal{crawledMeal})
+		if err != nil || len(storedMeals) != 1 || storedMeals[0].Id != cgmMeal.Id {
+			t.Fatalf("expected only the stored meal of cgm but got %v, %v", storedMeals, err)
+		}
+
+		changes := recordChanges(ctx, Job{Id: "changes-test"}, storedMeals, []webcrawler.Meal{crawledMeal})
+		for _, change := range changes {
+			defer persister.RemoveDocuments(config.Get().ChangeCollectionName, []string{change.Id}, ctx)
+			if change.MealId == otherMeal.Id {
+				t.Fatalf("expected no change of the meal of another source but got %+v", change)
+			}
+		}
+		if !persister.DocumentExists(config.Get().MealCollectionName, otherMeal.Id, ctx) {
+			t.Fatalf("expected the meal of another source to be kept")
+		}
+		if persister.DocumentExists(config.Get().MealCollectionName, cgmMeal.Id, ctx) {
+			t.Fatalf("expected the meal of cgm that is not offered anymore to be removed")
+		}
+	})
+}
+
+func TestDetectDrifts(t *testing.T) {
+	ctx := context.Background()
+	fingerprint := webcrawler.PageFingerprint{Source: "drift-test", ProfileVersion: 1, Hash: "hash", Shapes: []string{"day div"}}
+	defer persister.RemoveDocuments(config.Get().BaselineCollectionName, []string{fingerprint.BaselineId()}, ctx)
+
+	t.Run("expect a page without meals not to become the baseline", func(t *testing.T) {
+		detectDrifts(ctx, Job{}, []webcrawler.PageFingerprint{fingerprint}, false)
+
+		var baseline webcrawler.LayoutBaseline
+		persister.ReadDocumentIfExists(config.Get().BaselineCollectionName, fingerprint.BaselineId(), ctx, &baseline)
+		if baseline.Id != "" {
+			t.Fatalf("expected no baseline but got %+v", baseline)
+		}
+	})
+
+	t.Run("expect the first page with meals to become the baseline", func(t *testing.T) {
+		detectDrifts(ctx, Job{}, []webcrawler.PageFingerprint{fingerprint}, true)
+
+		var baseline webcrawler.LayoutBaseline
+		persister.ReadDocumentIfExists(config.Get().BaselineCollectionName, fingerprint.BaselineId(), ctx, &baseline)
+		if baseline.Fingerprint.Hash != fingerprint.Hash {
+			t.Fatalf("expected the fingerprint as baseline but got %+v", baseline)
+		}
+	})
+}
+
+func TestValidatorStore(t *testing.T) {
+	ctx := context.Background()
+	store := newValidatorStore(config.Get().ValidatorCollectionName)
+	pageUrl := "https://bistro.example/index.php?date=2020-06-08"
+
+	t.Run("expect saved validators to be loaded", func(t *testing.T) {
+		store.Save(ctx, pageUrl, webcrawler.PageValidators{ETag: `"week-24"`})
+		if validators, found := store.Load(ctx, pageUrl); !found || validators.ETag != `"week-24"` {
+			t.Fatalf("expected the etag of the page but got %+v", validators)
+		}
+	})
+
+	t.Run("expect forgotten validators to be gone", func(t *testing.T) {
+		store.Forget(ctx, pageUrl)
+		if validators, found := store.Load(ctx, pageUrl); found {
+			t.Fatalf("expected no validators but got %+v", validators)
 		}
 	})
 }
@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Runs the jobs of the queue with a bounded number of concurrent workers
+// A job is started as soon as a worker is free, the dispatcher is woken by new jobs, finished jobs and the idle poll
+type workerPool struct {
+	queue            Queue
+	workers          int // the number of jobs that run at the same time
+	workersPerSource int // the number of jobs of the same source that run at the same time, zero for no limit
+
+	dispatchMutex sync.Mutex // held from the dequeuing of a job until it is registered, so a cancellation never misses it
+
+	mutex     sync.Mutex
+	running   map[string]int         // the number of running jobs per source
+	total     int                    // the number of running jobs
+	jobsByKey map[string]*runningJob // the running jobs by their key
+
+	wake       chan struct{}
+	retryTimer *time.Timer // wakes the dispatcher once the next retry is due
+	retryTime  time.Time
+	stop       chan struct{}
+	stopOnce   sync.Once
+	stopped    chan struct{}
+	jobs       sync.WaitGroup
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+}
+
+// A job a worker processes, which can be cancelled on its own
+type runningJob struct {
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+// Creates a pool with at least one worker that runs the jobs of the queue
+func newWorkerPool(queue Queue, workers int, workersPerSource int) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobsCtx, cancel := context.WithCancel(context.Background())
+	return &workerPool{
+		queue:            queue,
+		workers:          workers,
+		workersPerSource: workersPerSource,
+		running:          make(map[string]int),
+		jobsByKey:        make(map[string]*runningJob),
+		wake:             make(chan struct{}, 1),
+		stop:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+		jobsCtx:          jobsCtx,
+		cancelJobs:       cancel,
+	}
+}
+
+// Starts dispatching the jobs of the queue
+func (pool *workerPool) start() {
+	go pool.dispatch()
+}
+
+// Wakes the dispatcher up, so it checks the queue for jobs
+// Never blocks, a pending wake up is enough for any number of notifications
+func (pool *workerPool) notify() {
+	select {
+	case pool.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wakes the dispatcher up at the specified time, unless it is woken up at an earlier time already
+func (pool *workerPool) notifyAt(wakeTime time.Time) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if pool.retryTimer != nil && pool.retryTime.After(time.Now()) && !pool.retryTime.After(wakeTime) {
+		return
+	}
+	if pool.retryTimer != nil {
+		pool.retryTimer.Stop()
+	}
+	pool.retryTime = wakeTime
+	pool.retryTimer = time.AfterFunc(time.Until(wakeTime), pool.notify)
+}
+
+// Starts jobs until the workers are busy or the queue is empty and waits for the next wake up
+func (pool *workerPool) dispatch() {
+	defer close(pool.stopped)
+
+	for {
+		pool.startJobs()
+
+		select {
+		case <-pool.stop:
+			return
+		case <-pool.wake:
+		}
+	}
+}
+
+// Releases orphaned jobs, enqueues due retries again and starts the pending jobs the free workers can take
+// Jobs of sources that reached their limit stay in the queue and do not block jobs of other sources
+func (pool *workerPool) startJobs() {
+	reclaimExpiredJobs(pool.jobsCtx)
+	if nextRetry := enqueueDueRetries(pool.jobsCtx, pool.queue); !nextRetry.IsZero() {
+		pool.notifyAt(nextRetry)
+	}
+
+	for {
+		busySources, free := pool.capacity()
+		if !free {
+			return
+		}
+
+		if !pool.startNextJob(busySources) {
+			return
+		}
+	}
+}
+
+// Dequeues the next job that crawls none of the busy sources and runs it
+// The job is registered before a cancellation can look for it, as its running status is visible once it is dequeued
+// Returns false if no job was started
+func (pool *workerPool) startNextJob(busySources []string) bool {
+	pool.dispatchMutex.Lock()
+	defer pool.dispatchMutex.Unlock()
+
+	job, found, err := pool.queue.Dequeue(pool.jobsCtx, busySources)
+	if err != nil {
+		log.Println("Dequeuing the next job failed: " + err.Error())
+		return false
+	}
+	if found {
+		pool.run(job)
+	}
+	return found
+}
+
+// Determines the sources that reached their limit and if a worker is free
+func (pool *workerPool) capacity() ([]string, bool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	busySources := make([]string, 0)
+	if pool.workersPerSource > 0 {
+		for source, count := range pool.running {
+			if count >= pool.workersPerSource {
+				busySources = append(busySources, source)
+			}
+		}
+	}
+	return busySources, pool.total < pool.workers
+}
+
+// Processes the job with a worker of its own and wakes the dispatcher once the job is finished
+// Every job gets a context of its own, so it can be cancelled without affecting the other jobs
+func (pool *workerPool) run(job Job) {
+	jobCtx, cancel := context.WithCancel(pool.jobsCtx)
+	worker := &runningJob{cancel: cancel, finished: make(chan struct{})}
+
+	pool.mutex.Lock()
+	pool.running[job.Source]++
+	pool.total++
+	pool.jobsByKey[job.Key] = worker
+	pool.mutex.Unlock()
+
+	pool.jobs.Add(1)
+	go func() {
+		defer pool.finish(job)
+		processJob(jobCtx, job)
+	}()
+}
+
+func (pool *workerPool) finish(job Job) {
+	pool.mutex.Lock()
+	worker := pool.jobsByKey[job.Key]
+	delete(pool.jobsByKey, job.Key)
+	pool.running[job.Source]--
+	if pool.running[job.Source] <= 0 {
+		delete(pool.running, job.Source)
+	}
+	pool.total--
+	pool.mutex.Unlock()
+
+	worker.cancel()
+	close(worker.finished)
+	pool.jobs.Done()
+	pool.notify()
+}
+
+// Cancels the context of a job this pool runs and waits until its worker stopped
+// Returns false if the pool does not run the job
+func (pool *workerPool) cancel(jobKey string) bool {
+	// a job that is being dequeued is registered before the dispatch mutex is released
+	pool.dispatchMutex.Lock()
+	pool.mutex.Lock()
+	worker, found := pool.jobsByKey[jobKey]
+	pool.mutex.Unlock()
+	pool.dispatchMutex.Unlock()
+	if !found {
+		return false
+	}
+
+	worker.cancel()
+	<-worker.finished
+	return true
+}
+
+// Stops starting jobs and waits until the running jobs are finished
+// Jobs that are still running once the context is done are interrupted and keep their running status,
+// so they are enqueued again after a restart as soon as their lease expires
+func (pool *workerPool) drain(ctx context.Context) error {
+	pool.stopOnce.Do(func() { close(pool.stop) })
+	<-pool.stopped
+
+	finished := make(chan struct{})
+	go func() {
+		pool.jobs.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		pool.cancelJobs()
+		<-finished
+		return fmt.Errorf("the running jobs were interrupted: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,225 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Holds the pending jobs in the order they are processed
+// All implementations are safe for concurrent use by the rest api and the scheduler
+type Queue interface {
+	// Appends a pending job at the end of the queue and persists it to the jobs collection
+	Enqueue(ctx context.Context, job Job) error
+
+	// Appends a pending job at the end of the queue if its stored status is still the previous status
+	// Returns false if the status changed in the meantime, e.g. because the job was cancelled
+	Requeue(ctx context.Context, job Job, previousStatus string) (bool, error)
+
+	// Removes the first job that crawls none of the excluded sources and marks it as running
+	// Returns false if the queue holds no such job
+	Dequeue(ctx context.Context, excludedSources []string) (Job, bool, error)
+
+	// Marks the pending job with the key as cancelled and removes it from the queue
+	// Returns false if the job is not pending
+	Remove(ctx context.Context, jobKey string) (bool, error)
+
+	// Returns a copy of the pending jobs, the head of the queue first
+	List(ctx context.Context) ([]Job, error)
+
+	// Removes all pending jobs from the queue and from the jobs collection
+	Clear(ctx context.Context) error
+}
+
+// Creates the queue of the configured kind
+// Returns an error if the kind is unknown
+func newConfiguredQueue(cfg config.Config) (Queue, error) {
+	switch cfg.JobQueue {
+	case "", "memory":
+		return NewMemoryQueue(cfg.JobCollectionName), nil
+	case "database":
+		return NewDatabaseQueue(cfg.JobCollectionName), nil
+	default:
+		return nil, fmt.Errorf("unknown job queue '%s', expected memory or database", cfg.JobQueue)
+	}
+}
+
+// Keeps the pending jobs of a single process in memory
+// The jobs are persisted nevertheless, so the queue can be recovered after a restart
+type memoryQueue struct {
+	mutex          sync.Mutex
+	jobs           []Job
+	collectionName string
+}
+
+// Creates an empty in-memory queue that persists its jobs to the named collection
+func NewMemoryQueue(collectionName string) Queue {
+	return &memoryQueue{jobs: make([]Job, 0), collectionName: collectionName}
+}
+
+func (queue *memoryQueue) Enqueue(ctx context.Context, job Job) error {
+	queue.mutex.Lock()
+	queue.jobs = append(queue.jobs, job)
+	queue.mutex.Unlock()
+
+	persister.PersistDocument(queue.collectionName, job, ctx)
+	return ctx.Err()
+}
+
+func (queue *memoryQueue) Requeue(ctx context.Context, job Job, previousStatus string) (bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	requeued, err := updateJobIf(ctx, queue.collectionName, job, previousStatus)
+	if requeued {
+		queue.jobs = append(queue.jobs, job)
+	}
+	return requeued, err
+}
+
+// The running status is persisted while the job is still guarded by the queue,
+// so a job that was cancelled in the meantime is dropped instead of started
+func (queue *memoryQueue) Dequeue(ctx context.Context, excludedSources []string) (Job, bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for i := 0; i < len(queue.jobs); {
+		job := queue.jobs[i]
+		if containsSource(excludedSources, job.Source) {
+			i++
+			continue
+		}
+
+		job.Status = "RUNNING"
+		job.StartedTime = time.Now().Format(time.RFC3339)
+		started, err := updateJobIf(ctx, queue.collectionName, job, "PENDING")
+		if err != nil {
+			return Job{}, false, err
+		}
+		queue.jobs = append(queue.jobs[:i:i], queue.jobs[i+1:]...)
+		if started {
+			return job, true, nil
+		}
+	}
+	return Job{}, false, nil
+}
+
+// A pending job that is missing in the queue is cancelled nevertheless, no worker can start it anymore
+func (queue *memoryQueue) Remove(ctx context.Context, jobKey string) (bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	removed, err := markCancelled(ctx, queue.collectionName, jobKey, "PENDING")
+	if err != nil {
+		return false, err
+	}
+	for i, job := range queue.jobs {
+		if job.Key == jobKey {
+			queue.jobs = append(queue.jobs[:i:i], queue.jobs[i+1:]...)
+			break
+		}
+	}
+	return removed, nil
+}
+
+func (queue *memoryQueue) List(ctx context.Context) ([]Job, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	return append(make([]Job, 0, len(queue.jobs)), queue.jobs...), nil
+}
+
+func (queue *memoryQueue) Clear(ctx context.Context) error {
+	queue.mutex.Lock()
+	removedJobs := queue.jobs
+	queue.jobs = make([]Job, 0)
+	queue.mutex.Unlock()
+
+	persister.RemoveDocuments(queue.collectionName, jobKeys(removedJobs), ctx)
+	return ctx.Err()
+}
+
+// Uses the pending jobs of the jobs collection as queue, so several processes can share it
+// A job is dequeued by switching its status to running within a single query, so every job runs only once
+type databaseQueue struct {
+	collectionName string
+}
+
+// Creates a queue over the pending jobs of the named collection
+func NewDatabaseQueue(collectionName string) Queue {
+	return &databaseQueue{collectionName: collectionName}
+}
+
+// The persisted pending job is the entry of the queue
+func (queue *databaseQueue) Enqueue(ctx context.Context, job Job) error {
+	persister.PersistDocument(queue.collectionName, job, ctx)
+	return ctx.Err()
+}
+
+func (queue *databaseQueue) Requeue(ctx context.Context, job Job, previousStatus string) (bool, error) {
+	return updateJobIf(ctx, queue.collectionName, job, previousStatus)
+}
+
+func (queue *databaseQueue) Dequeue(ctx context.Context, excludedSources []string) (Job, bool, error) {
+	update := map[string]interface{}{
+		"status":      "RUNNING",
+		"startedTime": time.Now().Format(time.RFC3339),
+	}
+
+	claimedJobs := make([]Job, 0, 1)
+	err := persister.UpdateFirstDocument(queue.collectionName, "status", "PENDING", "source", excludedSources, "enqueuedTime", update, ctx, &claimedJobs)
+	if err != nil || len(claimedJobs) == 0 {
+		return Job{}, false, err
+	}
+	return claimedJobs[0], true, nil
+}
+
+// The job is removed by switching its status, so no other process can dequeue it at the same time
+func (queue *databaseQueue) Remove(ctx context.Context, jobKey string) (bool, error) {
+	return markCancelled(ctx, queue.collectionName, jobKey, "PENDING")
+}
+
+func (queue *databaseQueue) List(ctx context.Context) ([]Job, error) {
+	pendingJobs := make([]Job, 0)
+	if err := persister.ReadDocumentsByAttribute(queue.collectionName, "status", []string{"PENDING"}, ctx, &pendingJobs); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pendingJobs, func(i, j int) bool {
+		return pendingJobs[i].EnqueuedTime < pendingJobs[j].EnqueuedTime
+	})
+	return pendingJobs, nil
+}
+
+func (queue *databaseQueue) Clear(ctx context.Context) error {
+	pendingJobs, err := queue.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	persister.RemoveDocuments(queue.collectionName, jobKeys(pendingJobs), ctx)
+	return ctx.Err()
+}
+
+// Collects the database keys of the jobs
+func jobKeys(jobs []Job) []string {
+	keys := make([]string, len(jobs))
+	for i := range jobs {
+		keys[i] = jobs[i].Key
+	}
+	return keys
+}
+
+// Tells if the source is one of the sources
+func containsSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"log"
+	"sort"
+	"time"
+)
+
+// Is recorded as error of an attempt whose process stopped before the job finished
+// A job that keeps its worker from finishing, e.g. by crashing the process, must not be retried forever,
+// so its expired leases count as failed attempts of its retry policy
+var errLeaseExpired = errors.New("the lease of the job expired")
+
+// The time the leases of the running jobs were checked last
+var lastLeaseCheck time.Time
+
+// Rebuilds the queue from the pending jobs of the jobs collection, the oldest job first
+// Running jobs whose lease expired are orphans of a stopped process, their retry policy decides if they are retried
+// Requeuing a job that is still pending persists it unchanged, so a database queue stays as it is
+func recoverJobQueue(ctx context.Context) error {
+	storedJobs := make([]Job, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().JobCollectionName, "status", []string{"PENDING", "RUNNING"}, ctx, &storedJobs)
+	if err != nil {
+		return fmt.Errorf("reading the unfinished jobs failed: %w", err)
+	}
+
+	now := time.Now()
+	lastLeaseCheck = now
+	pendingJobs := make([]Job, 0, len(storedJobs))
+	for _, job := range storedJobs {
+		if job.Status == "RUNNING" {
+			if leaseExpired(job, now) {
+				releaseJob(ctx, job, now)
+			}
+			continue
+		}
+		pendingJobs = append(pendingJobs, job)
+	}
+
+	sort.SliceStable(pendingJobs, func(i, j int) bool {
+		return pendingJobs[i].EnqueuedTime < pendingJobs[j].EnqueuedTime
+	})
+	for _, job := range pendingJobs {
+		if _, err := jobQueue.Requeue(ctx, job, "PENDING"); err != nil {
+			return fmt.Errorf("enqueuing the job %s again failed: %w", job.Id, err)
+		}
+	}
+	log.Printf("Recovered %d unfinished jobs from the jobs collection", len(pendingJobs))
+	return nil
+}
+
+// Releases the running jobs whose lease expired, at most once per lease timeout
+// Jobs of this process never exceed their lease, because the lease timeout is longer than the job timeout
+// The released jobs are enqueued again once their retry is due
+func reclaimExpiredJobs(ctx context.Context) {
+	now := time.Now()
+	if now.Sub(lastLeaseCheck) < leaseTimeout() {
+		return
+	}
+	lastLeaseCheck = now
+
+	runningJobs := make([]Job, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().JobCollectionName, "status", []string{"RUNNING"}, ctx, &runningJobs)
+	if err != nil {
+		log.Println("Reading the running jobs failed: " + err.Error())
+		return
+	}
+
+	for _, job := range runningJobs {
+		if leaseExpired(job, now) {
+			releaseJob(ctx, job, now)
+		}
+	}
+}
+
+// A job holds its lease from its start until the lease timeout is exceeded
+// Jobs without a valid start time never got a lease
+func leaseExpired(job Job, now time.Time) bool {
+	startedTime, err := time.Parse(time.RFC3339, job.StartedTime)
+	return err != nil || now.Sub(startedTime) >= leaseTimeout()
+}
+
+// Finishes the attempt of a job whose lease expired as failed and persists the job if it is still running
+// The retry policy of the job decides if it is failed, dead-lettered or retried later
+func releaseJob(ctx context.Context, job Job, now time.Time) {
+	expireLease(&job, now)
+	if _, err := updateJobIf(ctx, config.Get().JobCollectionName, job, "RUNNING"); err != nil {
+		log.Println("Releasing the job " + job.Id + " failed: " + err.Error())
+	}
+}
+
+// Records the expired lease of a job as failed attempt and schedules its retry
+func expireLease(job *Job, now time.Time) {
+	err := fmt.Errorf("%w, the job started at %s did not finish", errLeaseExpired, job.StartedTime)
+	job.Recoveries++
+	job.FinishedTime = now.Format(time.RFC3339)
+	job.Additional = append(job.Additional, err.Error())
+	scheduleRetry(job, err, now)
+	recordAttempt(job, err)
+}
+
+// The time a running job may go without finishing before it is considered orphaned
+func leaseTimeout() time.Duration {
+	return time.Duration(config.Get().JobLeaseTimeoutInSeconds) * time.Second
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Classes of the errors a job can fail with, a retry policy lists the classes worth a retry
+const (
+	ErrorClassTimeout = "timeout" // the job or a request exceeded its deadline
+	ErrorClassNetwork = "network" // a connection could not be established or broke off
+	ErrorClassServer  = "server"  // the host answered with a server error or asked to slow down
+	ErrorClassClient  = "client"  // the host refused the request, e.g. with 404
+	ErrorClassRobots  = "robots"  // the robots.txt of the host disallows the page
+	ErrorClassParse   = "parse"   // the page could not be parsed
+	ErrorClassOther   = "other"   // any other error
+)
+
+// Returned when no job exists with the requested id
+var ErrJobNotFound = errors.New("no job found")
+
+// Returned when a job is requeued that has not finished with FAILURE or DEAD_LETTER
+var ErrJobNotRequeueable = errors.New("only failed and dead-lettered jobs can be requeued")
+
+// Describes how often and when a failed job is attempted again
+// The delay before a retry doubles with every failed attempt, starting at the delay and never exceeding the max delay
+type RetryPolicy struct {
+	MaxAttempts           int      `json:"maxAttempts"`           // attempts including the first one, a job that uses them up is dead-lettered
+	DelayInSeconds        uint64   `json:"delayInSeconds"`        // the delay before the first retry
+	MaxDelayInSeconds     uint64   `json:"maxDelayInSeconds"`     // the longest delay before a retry
+	RetryableErrorClasses []string `json:"retryableErrorClasses"` // timeout | network | server | client | robots | parse | other
+}
+
+// Represents a single attempt to process a job
+type JobAttempt struct {
+	Number       int    `json:"number"`               // the position of the attempt, starting at 1
+	StartedTime  string `json:"startedTime"`          // the time the attempt started
+	FinishedTime string `json:"finishedTime"`         // the time the attempt finished
+	Status       string `json:"status"`               // the status the attempt finished with
+	Error        string `json:"error,omitempty"`      // why the attempt failed
+	ErrorClass   string `json:"errorClass,omitempty"` // the class of the error, which decides about a retry
+}
+
+// Creates the configured retry policy, which every new job gets
+func defaultRetryPolicy() RetryPolicy {
+	cfg := config.Get()
+	return RetryPolicy{
+		MaxAttempts:           int(cfg.JobRetryAttempts),
+		DelayInSeconds:        cfg.JobRetryDelayInSeconds,
+		MaxDelayInSeconds:     cfg.JobRetryMaxDelayInSeconds,
+		RetryableErrorClasses: cfg.JobRetryErrorClasses,
+	}
+}
+
+// Tells if errors of the class are worth a retry
+func (policy RetryPolicy) retries(errorClass string) bool {
+	for _, retryableClass := range policy.RetryableErrorClasses {
+		if retryableClass == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculates the delay before the retry that follows the specified number of failed attempts
+func (policy RetryPolicy) delay(failedAttempts int) time.Duration {
+	delay := time.Duration(policy.DelayInSeconds) * time.Second
+	maxDelay := time.Duration(policy.MaxDelayInSeconds) * time.Second
+	for i := 1; i < failedAttempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Determines the class of the error a job failed with
+func classifyError(err error) string {
+	var statusErr *webcrawler.HttpStatusError
+	var robotsErr *webcrawler.RobotsError
+	var parseErr *webcrawler.ParseError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errLeaseExpired):
+		return ErrorClassTimeout
+	case errors.As(err, &statusErr):
+		if statusErr.Temporary() || statusErr.StatusCode == http.StatusTooManyRequests {
+			return ErrorClassServer
+		}
+		return ErrorClassClient
+	case errors.As(err, &robotsErr):
+		return ErrorClassRobots
+	case errors.As(err, &parseErr):
+		return ErrorClassParse
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	default:
+		return ErrorClassOther
+	}
+}
+
+// Appends the attempt that just finished to the attempts of the job
+func recordAttempt(job *Job, err error) {
+	attempt := JobAttempt{
+		Number:       len(job.Attempts) + 1,
+		StartedTime:  job.StartedTime,
+		FinishedTime: job.FinishedTime,
+		Status:       job.Status,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.ErrorClass = classifyError(err)
+	}
+	job.Attempts = append(job.Attempts, attempt)
+}
+
+// Decides what happens to a job after a failed attempt
+// Errors the policy does not retry fail the job, a job that used up its attempts is dead-lettered
+// and all other jobs are scheduled for a retry after the delay of the policy
+func scheduleRetry(job *Job, err error, now time.Time) {
+	job.FailedAttempts++
+	errorClass := classifyError(err)
+
+	switch {
+	case !job.RetryPolicy.retries(errorClass):
+		job.Status = "FAILURE"
+	case job.FailedAttempts >= job.RetryPolicy.MaxAttempts:
+		job.Status = "DEAD_LETTER"
+	default:
+		job.Status = "RETRY_SCHEDULED"
+		job.NextAttemptTime = now.Add(job.RetryPolicy.delay(job.FailedAttempts)).Format(time.RFC3339)
+	}
+}
+
+// Enqueues the jobs whose retry is due
+// A job is only enqueued if its retry is still scheduled, so a job that was cancelled meanwhile is not retried
+// Returns the time of the next retry that is not due yet, zero if no retry is scheduled
+func enqueueDueRetries(ctx context.Context, queue Queue) time.Time {
+	scheduledJobs := make([]Job, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().JobCollectionName, "status", []string{"RETRY_SCHEDULED"}, ctx, &scheduledJobs)
+	if err != nil {
+		log.Println("Reading the scheduled retries failed: " + err.Error())
+		return time.Time{}
+	}
+
+	now := time.Now()
+	var nextRetry time.Time
+	for _, job := range scheduledJobs {
+		retryTime, err := time.Parse(time.RFC3339, job.NextAttemptTime)
+		if err == nil && retryTime.After(now) {
+			if nextRetry.IsZero() || retryTime.Before(nextRetry) {
+				nextRetry = retryTime
+			}
+			continue
+		}
+
+		job.Status = "PENDING"
+		job.NextAttemptTime = ""
+		job.StartedTime, job.FinishedTime = "", ""
+		if _, err := queue.Requeue(ctx, job, "RETRY_SCHEDULED"); err != nil {
+			log.Println("Enqueuing the retry of the job " + job.Id + " failed: " + err.Error())
+		}
+	}
+	return nextRetry
+}
+
+// Returns all jobs that used up their attempts, the latest first
+func DeadLetterJobs(ctx context.Context) ([]Job, error) {
+	deadLetters := make([]Job, 0)
+	err := persister.ReadDocumentsByAttribute(config.Get().JobCollectionName, "status", []string{"DEAD_LETTER"}, ctx, &deadLetters)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FinishedTime > deadLetters[j].FinishedTime
+	})
+	return deadLetters, nil
+}
+
+// Enqueues a failed or dead-lettered job again with all attempts of its retry policy
+// The attempts made so far are kept
+// Returns the requeued job, ErrJobNotFound or ErrJobNotRequeueable
+func RequeueJob(ctx context.Context, jobId string) (Job, error) {
+	job, err := readJob(ctx, jobId)
+	if err != nil {
+		return job, err
+	}
+	if job.Status != "FAILURE" && job.Status != "DEAD_LETTER" {
+		return job, fmt.Errorf("%w, the job %s is %s", ErrJobNotRequeueable, jobId, job.Status)
+	}
+
+	previousStatus := job.Status
+	job.Status = "PENDING"
+	job.FailedAttempts = 0
+	job.NextAttemptTime = ""
+	job.StartedTime, job.FinishedTime = "", ""
+	job.EnqueuedTime = time.Now().Format(time.RFC3339)
+	requeued, err := jobQueue.Requeue(ctx, job, previousStatus)
+	if err != nil {
+		return job, err
+	}
+	if !requeued {
+		return job, fmt.Errorf("%w, the job %s changed its status in the meantime", ErrJobNotRequeueable, jobId)
+	}
+	workers.notify()
+	return job, nil
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+)
+
+// Keeps the validators of the fetched pages in a collection, so recurring crawls stay conditional after a restart
+// Every page is stored under the sha1 hash of its url, as urls contain characters that are no valid document keys
+type validatorStore struct {
+	collectionName string
+}
+
+// The validators of a page as stored in the collection
+type storedValidators struct {
+	Key string `json:"_key,omitempty"` // the sha1 hash of the url
+	Url string `json:"url"`            // the url of the page
+	webcrawler.PageValidators
+}
+
+func (validators storedValidators) GetId() string {
+	return validators.Key
+}
+
+// Creates a store that keeps the validators in the named collection
+func newValidatorStore(collectionName string) webcrawler.ValidatorStore {
+	return &validatorStore{collectionName: collectionName}
+}
+
+func (store *validatorStore) Load(ctx context.Context, pageUrl string) (webcrawler.PageValidators, bool) {
+	var validators storedValidators
+	persister.ReadDocumentIfExists(store.collectionName, validatorKey(pageUrl), ctx, &validators)
+	return validators.PageValidators, validators.Key != ""
+}
+
+func (store *validatorStore) Save(ctx context.Context, pageUrl string, validators webcrawler.PageValidators) {
+	persister.PersistDocument(store.collectionName, storedValidators{Key: validatorKey(pageUrl), Url: pageUrl, PageValidators: validators}, ctx)
+}
+
+func (store *validatorStore) Forget(ctx context.Context, pageUrl string) {
+	persister.RemoveDocuments(store.collectionName, []string{validatorKey(pageUrl)}, ctx)
+}
+
+// Derives the document key of the validators of a page from its url
+func validatorKey(pageUrl string) string {
+	hash := sha1.Sum([]byte(pageUrl))
+	return hex.EncodeToString(hash[:])
+}
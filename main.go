@@ -1,10 +1,37 @@
 package main
 
 import (
+	"context"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/jobs"
 	"github.com/Rate-My-Bistro/crawler/restapi"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // application entrypoint
+// On an interrupt or termination signal the rest api stops accepting requests, no further jobs are started
+// and the running jobs are drained
 func main() {
-	restapi.Serve()
+	server := restapi.NewServer()
+	go restapi.Serve(server)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Println("Shutting down, waiting for the running jobs to finish")
+	drainTimeout := time.Duration(config.Get().JobDrainTimeoutInSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println(err)
+	}
+	if err := jobs.Drain(ctx); err != nil {
+		log.Println(err)
+	}
 }
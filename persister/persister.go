@@ -12,6 +12,7 @@ import (
 	"github.com/arangodb/go-driver/http"
 	"github.com/avast/retry-go"
 	"log"
+	"reflect"
 	"time"
 )
 
@@ -29,6 +30,12 @@ func init() {
 	createDatabase()
 	ensureCollection(config.Get().MealCollectionName)
 	ensureCollection(config.Get().JobCollectionName)
+	ensureCollection(config.Get().ChangeCollectionName)
+	ensureCollection(config.Get().DayCollectionName)
+	ensureCollection(config.Get().BaselineCollectionName)
+	ensureCollection(config.Get().DriftCollectionName)
+	ensureCollection(config.Get().DietOverrideCollectionName)
+	ensureCollection(config.Get().ValidatorCollectionName)
 }
 
 func waitForDataBaseToBecomeReady() {
@@ -56,22 +63,49 @@ func waitForDataBaseToBecomeReady() {
 
 // persists the passed documents into the database
 // the parameter databaseAddress defines the database target
-func PersistDocuments(collectionName string, documents []Identifiable) {
+// the context may be nil, the documents are persisted without deadline then
+func PersistDocuments(collectionName string, documents []Identifiable, ctx context.Context) {
 	for _, document := range documents {
-		createOrUpdateDocument(collectionName, document)
+		createOrUpdateDocument(collectionName, document, ctx)
 	}
 }
 
 // persists the passed document into the database
 // the parameter databaseAddress defines the database target
-func PersistDocument(collectionName string, document Identifiable) {
-	createOrUpdateDocument(collectionName, document)
+func PersistDocument(collectionName string, document Identifiable, ctx context.Context) {
+	createOrUpdateDocument(collectionName, document, ctx)
+}
+
+// replaces the passed documents in the database or creates them if they do not exist yet
+// other than an update, a replacement drops the attributes a document does not have anymore,
+// e.g. the attributes left out as empty
+// the context may be nil, the documents are replaced without deadline then
+func ReplaceDocuments(collectionName string, documents []Identifiable, ctx context.Context) {
+	for _, document := range documents {
+		createOrReplaceDocument(collectionName, document, ctx)
+	}
+}
+
+// Creates a new document document if it does not exists yet
+// Otherwise it will replaced as a whole, identified by the key
+func createOrReplaceDocument(collectionName string, document Identifiable, ctx context.Context) {
+	trxId, transactionContext := startTransaction(collectionName, ctx)
+
+	if DocumentExists(collectionName, document.GetId(), transactionContext) {
+		replaceDocument(collectionName, document, transactionContext)
+	} else {
+		createDocument(collectionName, document, transactionContext)
+	}
+
+	if err := database.CommitTransaction(transactionContext, trxId, nil); err != nil {
+		log.Printf("Failed to commit transaction for document %s: %s", document.GetId(), err)
+	}
 }
 
 // Creates a new document document if it does not exists yet
 // Otherwise it will updated, identified by the key
-func createOrUpdateDocument(collectionName string, document Identifiable) {
-	trxId, transactionContext := startTransaction(collectionName)
+func createOrUpdateDocument(collectionName string, document Identifiable, ctx context.Context) {
+	trxId, transactionContext := startTransaction(collectionName, ctx)
 
 	if DocumentExists(collectionName, document.GetId(), transactionContext) {
 		updateDocument(collectionName, document, transactionContext)
@@ -86,13 +120,16 @@ func createOrUpdateDocument(collectionName string, document Identifiable) {
 
 // initiate a new database transactions
 // returns the transaction id and the transaction context
-func startTransaction(collectionName string) (driver.TransactionID, context.Context) {
-	bgContext := context.Background()
-	trxId, err := database.BeginTransaction(bgContext, driver.TransactionCollections{Exclusive: []string{collectionName}}, nil)
+func startTransaction(collectionName string, ctx context.Context) (driver.TransactionID, context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	trxId, err := database.BeginTransaction(ctx, driver.TransactionCollections{Exclusive: []string{collectionName}}, nil)
 	if err != nil {
 		log.Printf("Failed to begin transaction: %s", err)
 	}
-	transactionContext := driver.WithTransactionID(bgContext, trxId)
+	transactionContext := driver.WithTransactionID(ctx, trxId)
 	return trxId, transactionContext
 }
 
@@ -136,6 +173,19 @@ func updateDocument(collectionName string, document Identifiable, ctx context.Co
 	}
 }
 
+// Replaces an existing document document
+// If it does not exists this function will fail
+func replaceDocument(collectionName string, document Identifiable, ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, err := collections[collectionName].ReplaceDocument(ctx, document.GetId(), document)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
 // creates a new document document
 // if a document with the same key already exists this function will fail
 func createDocument(collectionName string, document Identifiable, ctx context.Context) {
@@ -166,9 +216,116 @@ func ReadDocument(collectionName string, key string, ctx context.Context, result
 
 // Retrieve a document by its key
 // If no document exists with given key, an empty document is returned
-func ReadDocumentIfExists(collectionName string, key string, result interface{}) {
-	if DocumentExists(collectionName, key, nil) {
-		ReadDocument(collectionName, key, nil, result)
+func ReadDocumentIfExists(collectionName string, key string, ctx context.Context, result interface{}) {
+	if DocumentExists(collectionName, key, ctx) {
+		ReadDocument(collectionName, key, ctx, result)
+	}
+}
+
+// Reads all documents of a collection whose attribute holds one of the specified values
+// The result must be a pointer to a slice, every found document is appended as a new element
+func ReadDocumentsByAttribute(collectionName string, attribute string, values []string, ctx context.Context, result interface{}) error {
+	query := "FOR document IN @@collection FILTER document[@attribute] IN @values RETURN document"
+	bindVars := map[string]interface{}{
+		"@collection": collectionName,
+		"attribute":   attribute,
+		"values":      values,
+	}
+	return queryDocuments(query, bindVars, ctx, result)
+}
+
+// Reads all documents of a collection
+// The result must be a pointer to a slice, every document is appended as a new element
+func ReadAllDocuments(collectionName string, ctx context.Context, result interface{}) error {
+	query := "FOR document IN @@collection RETURN document"
+	bindVars := map[string]interface{}{
+		"@collection": collectionName,
+	}
+	return queryDocuments(query, bindVars, ctx, result)
+}
+
+// Updates the first document whose attribute holds the value, in the order of the sort attribute
+// Documents whose excluded attribute holds one of the excluded values are skipped
+// The document is selected and updated by a single query, so concurrent callers never update the same document
+// The result must be a pointer to a slice, the updated document is appended if a document was found
+func UpdateFirstDocument(collectionName string, attribute string, value string, excludedAttribute string, excludedValues []string,
+	sortAttribute string, update map[string]interface{}, ctx context.Context, result interface{}) error {
+	query := "FOR document IN @@collection FILTER document[@attribute] == @value FILTER document[@excludedAttribute] NOT IN @excludedValues " +
+		"SORT document[@sortAttribute] LIMIT 1 UPDATE document WITH @update IN @@collection RETURN NEW"
+	if excludedValues == nil {
+		excludedValues = make([]string, 0)
+	}
+	bindVars := map[string]interface{}{
+		"@collection":       collectionName,
+		"attribute":         attribute,
+		"value":             value,
+		"excludedAttribute": excludedAttribute,
+		"excludedValues":    excludedValues,
+		"sortAttribute":     sortAttribute,
+		"update":            update,
+	}
+	return queryDocuments(query, bindVars, ctx, result)
+}
+
+// Updates the document with the key if its attribute holds the value
+// The document is checked and updated by a single query, so a concurrent change of the attribute is never overwritten
+// The result must be a pointer to a slice, the updated document is appended if the document was updated
+func UpdateDocumentIf(collectionName string, key string, attribute string, value string, update map[string]interface{}, ctx context.Context, result interface{}) error {
+	query := "FOR document IN @@collection FILTER document._key == @key FILTER document[@attribute] == @value " +
+		"UPDATE document WITH @update IN @@collection RETURN NEW"
+	bindVars := map[string]interface{}{
+		"@collection": collectionName,
+		"key":         key,
+		"attribute":   attribute,
+		"value":       value,
+		"update":      update,
+	}
+	return queryDocuments(query, bindVars, ctx, result)
+}
+
+// Runs a query and appends every returned document to the result
+// The result must be a pointer to a slice of the document type
+func queryDocuments(query string, bindVars map[string]interface{}, ctx context.Context, result interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cursor, err := database.Query(ctx, query, bindVars)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	resultSlice := reflect.ValueOf(result).Elem()
+	for cursor.HasMore() {
+		document := reflect.New(resultSlice.Type().Elem())
+		if _, err := cursor.ReadDocument(ctx, document.Interface()); err != nil {
+			return err
+		}
+		resultSlice.Set(reflect.Append(resultSlice, document.Elem()))
+	}
+
+	return nil
+}
+
+// Removes all documents with the specified keys
+// Keys without a document are ignored
+func RemoveDocuments(collectionName string, keys []string, ctx context.Context) {
+	if len(keys) == 0 {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, errs, err := collections[collectionName].RemoveDocuments(ctx, keys)
+	if err != nil {
+		log.Print(err)
+	}
+	for _, removeErr := range errs {
+		if removeErr != nil && !driver.IsNotFound(removeErr) {
+			log.Print(removeErr)
+		}
 	}
 }
 
@@ -13,6 +13,7 @@ func TestInsertOrUpdate(t *testing.T) {
 	createDatabase()
 	ensureCollection(config.Get().MealCollectionName)
 	ensureCollection(config.Get().JobCollectionName)
+	ensureCollection(config.Get().ChangeCollectionName)
 
 	meal1Stub := webcrawler.Meal{
 		Id: "abc",
@@ -22,32 +23,34 @@ func TestInsertOrUpdate(t *testing.T) {
 		Id:    "abc",
 		Date:  "2020-07-24",
 		Name:  "Suppe",
-		Price: 3.97,
+		Price: webcrawler.EuroCents(397),
 		MandatorySupplements: []webcrawler.Supplement{
-			{Name: "Reis", Price: 0}},
+			{Name: "Reis", Price: webcrawler.EuroCents(0)}},
 		OptionalSupplements: []webcrawler.Supplement{
-			{Name: "Markklößchen", Price: 0.12},
-			{Name: "Trokenes Brot", Price: 9.87}},
+			{Name: "Markklößchen", Price: webcrawler.EuroCents(12)},
+			{Name: "Trokenes Brot", Price: webcrawler.EuroCents(987)}},
+		Allergens: []string{webcrawler.AllergenGluten, webcrawler.AllergenCelery},
+		Additives: []string{webcrawler.AdditivePreservative},
 	}
 
 	meal2 := webcrawler.Meal{
 		Id:                   "b",
 		Date:                 "2020-07-24",
 		Name:                 "Reis",
-		Price:                1.44,
-		MandatorySupplements: []webcrawler.Supplement{{Name: "Salz", Price: 0}},
-		OptionalSupplements:  []webcrawler.Supplement{{Name: "Chilli", Price: 1}},
+		Price:                webcrawler.EuroCents(144),
+		MandatorySupplements: []webcrawler.Supplement{{Name: "Salz", Price: webcrawler.EuroCents(0)}},
+		OptionalSupplements:  []webcrawler.Supplement{{Name: "Chilli", Price: webcrawler.EuroCents(100)}},
 	}
 
 	t.Run("insert a record and update it", func(t *testing.T) {
 
-		createOrUpdateDocument(config.Get().MealCollectionName, Identifiable(meal1Stub))
+		createOrUpdateDocument(config.Get().MealCollectionName, Identifiable(meal1Stub), nil)
 
 		if !DocumentExists(config.Get().MealCollectionName, meal1Stub.Id, nil) {
 			t.Errorf("meal could not created")
 		}
 
-		createOrUpdateDocument(config.Get().MealCollectionName, meal1)
+		createOrUpdateDocument(config.Get().MealCollectionName, meal1, nil)
 
 		var meal webcrawler.Meal
 		ReadDocument(config.Get().MealCollectionName, meal1Stub.Id, context.Background(), &meal)
@@ -56,21 +59,77 @@ func TestInsertOrUpdate(t *testing.T) {
 			t.Errorf("meal was not updated")
 		}
 
-		if !(meal.MandatorySupplements[0].Price == 0) {
+		if !(meal.MandatorySupplements[0].Price == webcrawler.EuroCents(0)) {
 			t.Errorf("mandadory supplement price shoud be 0")
 		}
 
-		if !(meal.OptionalSupplements[1].Price == 9.87) {
+		if !(meal.OptionalSupplements[1].Price == webcrawler.EuroCents(987)) {
 			t.Errorf("optional supplement price was not updated")
 		}
 
-		createOrUpdateDocument(config.Get().MealCollectionName, meal2)
+		if !(len(meal.Allergens) == 2 && meal.Allergens[1] == webcrawler.AllergenCelery) {
+			t.Errorf("allergens were not persisted")
+		}
+
+		if !(len(meal.Additives) == 1 && meal.Additives[0] == webcrawler.AdditivePreservative) {
+			t.Errorf("additives were not persisted")
+		}
+
+		createOrUpdateDocument(config.Get().MealCollectionName, meal2, nil)
 
 		if !DocumentExists(config.Get().MealCollectionName, meal2.Id, nil) {
 			t.Errorf("meal could not created")
 		}
 	})
 
+	t.Run("read records by an attribute and remove them", func(t *testing.T) {
+		var meals []webcrawler.Meal
+		err := ReadDocumentsByAttribute(config.Get().MealCollectionName, "date", []string{"2020-07-24"}, nil, &meals)
+
+		if err != nil || len(meals) != 2 {
+			t.Errorf("expected 2 meals of the date but got %d: %v", len(meals), err)
+		}
+
+		RemoveDocuments(config.Get().MealCollectionName, []string{meal1.Id, meal2.Id}, nil)
+
+		if DocumentExists(config.Get().MealCollectionName, meal1.Id, nil) {
+			t.Errorf("meal could not removed")
+		}
+	})
+
 	removeDocument(config.Get().MealCollectionName, meal1.Id)
 	removeDocument(config.Get().MealCollectionName, meal2.Id)
 }
+
+func TestReplaceDocuments(t *testing.T) {
+	createClient()
+	createDatabase()
+	ensureCollection(config.Get().MealCollectionName)
+
+	kcal := 650.0
+	storedMeal := webcrawler.Meal{
+		Id:        "replaced",
+		Date:      "2020-07-24",
+		Name:      "Suppe",
+		Price:     webcrawler.EuroCents(397),
+		Nutrition: &webcrawler.Nutrition{Kcal: &kcal},
+		DetailUrl: "https://bistro.example/details/1",
+		Images:    []webcrawler.MealImage{{SourceUrl: "https://bistro.example/images/1.png"}},
+	}
+	crawledMeal := webcrawler.Meal{Id: storedMeal.Id, Date: storedMeal.Date, Name: storedMeal.Name, Price: storedMeal.Price}
+	defer removeDocument(config.Get().MealCollectionName, storedMeal.Id)
+
+	t.Run("expect a replaced meal to drop the details it does not list anymore", func(t *testing.T) {
+		ReplaceDocuments(config.Get().MealCollectionName, []Identifiable{storedMeal}, nil)
+		ReplaceDocuments(config.Get().MealCollectionName, []Identifiable{crawledMeal}, nil)
+
+		var meal webcrawler.Meal
+		ReadDocument(config.Get().MealCollectionName, storedMeal.Id, context.Background(), &meal)
+		if meal.Name != crawledMeal.Name || meal.Nutrition != nil || meal.DetailUrl != "" {
+			t.Fatalf("expected the meal without nutrition and detail page but got %+v", meal)
+		}
+		if len(meal.Images) > 0 {
+			t.Fatalf("expected the meal without images but got %v", meal.Images)
+		}
+	})
+}
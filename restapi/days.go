@@ -0,0 +1,55 @@
+package restapi
+
+import (
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// See Declarative Comments Format: https://swaggo.github.io/swaggo.io/declarative_comments_format/general_api_info.html
+
+// dayGet godoc
+// @Summary Retrieve the state of a day
+// @Description get whether the bistro is open, closed or the menu could not be parsed on the specified date
+// @Tags days
+// @Accept plain/text
+// @Produce application/json
+// @Param date path string true "Date in yyyy-mm-dd"
+// @Param source query string false "Name of the menu source, defaults to cgm"
+// @Success 200 {object} webcrawler.Day
+// @Failure 400 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /days/{date} [get]
+func dayGetWithParameter() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		date := c.Param("date")
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			NewError(c, http.StatusBadRequest, fmt.Errorf("invalid date format, expected was 'yyyy-mm-dd' but got %s", date))
+			return
+		}
+
+		sourceName := c.DefaultQuery("source", webcrawler.DefaultSourceName)
+		if _, err := webcrawler.GetSource(sourceName); err != nil {
+			NewError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		handleGetWithDateParameter(c, sourceName, date)
+	}
+}
+
+// Define the handler for a GET request with date parameter
+func handleGetWithDateParameter(c *gin.Context, sourceName string, date string) {
+	var day webcrawler.Day
+	persister.ReadDocumentIfExists(config.Get().DayCollectionName, webcrawler.DayId(sourceName, date), c.Request.Context(), &day)
+	if day.Id == "" {
+		NewError(c, http.StatusNotFound, fmt.Errorf("no crawled day of source %s found for date %s", sourceName, date))
+	} else {
+		c.JSON(http.StatusOK, day)
+	}
+}
@@ -0,0 +1,29 @@
+package restapi
+
+import (
+	"github.com/Rate-My-Bistro/crawler/jobs"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// See Declarative Comments Format: https://swaggo.github.io/swaggo.io/declarative_comments_format/general_api_info.html
+
+// deadLetterGet godoc
+// @Summary Get all dead-lettered jobs
+// @Description get all jobs that used up the attempts of their retry policy, the latest first, requeue them with POST /jobs/{id}/requeue
+// @Tags dead-letters
+// @Accept plain/text
+// @Produce application/json
+// @Success 200 {array} jobs.Job
+// @Failure 500 {object} HTTPError
+// @Router /dead-letters [get]
+func deadLetterGet() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		deadLetters, err := jobs.DeadLetterJobs(c.Request.Context())
+		if err != nil {
+			NewError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, deadLetters)
+	}
+}
@@ -0,0 +1,100 @@
+package restapi
+
+import (
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/jobs"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// See Declarative Comments Format: https://swaggo.github.io/swaggo.io/declarative_comments_format/general_api_info.html
+
+// dietOverrideGet godoc
+// @Summary Get all diet overrides
+// @Description get all manually set diet tags, sorted by meal name
+// @Tags diet-overrides
+// @Accept plain/text
+// @Produce application/json
+// @Success 200 {array} webcrawler.DietOverride
+// @Failure 500 {object} HTTPError
+// @Router /diet-overrides [get]
+func dietOverrideGet() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		overrides := make([]webcrawler.DietOverride, 0)
+		err := persister.ReadAllDocuments(config.Get().DietOverrideCollectionName, c.Request.Context(), &overrides)
+		if err != nil {
+			NewError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		sort.SliceStable(overrides, func(i, j int) bool {
+			return overrides[i].MealName < overrides[j].MealName
+		})
+		c.JSON(http.StatusOK, overrides)
+	}
+}
+
+// dietOverridePut godoc
+// @Summary Override the diet tags of a meal
+// @Description set the diet tags of all meals with the same name, which take priority over the classified tags
+// @Description the stored meals whose name differs only in case, whitespace, accents or punctuation are updated at once
+// @Tags diet-overrides
+// @Accept application/json
+// @Produce application/json
+// @Param override body webcrawler.DietOverride true "Meal name and its tags (VEGETARIAN, VEGAN, CONTAINS_PORK or CONTAINS_FISH)"
+// @Success 200 {object} webcrawler.DietOverride
+// @Failure 400 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /diet-overrides [put]
+func dietOverridePut() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var override webcrawler.DietOverride
+		if err := c.ShouldBindJSON(&override); err != nil {
+			NewError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if strings.TrimSpace(override.MealName) == "" {
+			NewError(c, http.StatusBadRequest, fmt.Errorf("no meal name found in request body"))
+			return
+		}
+		for _, tag := range override.Tags {
+			if !webcrawler.IsDietTag(tag) {
+				NewError(c, http.StatusBadRequest, fmt.Errorf("unknown diet tag '%s', expected VEGETARIAN, VEGAN, CONTAINS_PORK or CONTAINS_FISH", tag))
+				return
+			}
+		}
+		if override.Tags == nil {
+			override.Tags = make([]string, 0)
+		}
+
+		override.Id = webcrawler.DietOverrideId(override.MealName)
+		override.UpdatedTime = time.Now().Format(time.RFC3339)
+		persister.PersistDocument(config.Get().DietOverrideCollectionName, override, c.Request.Context())
+
+		// overrides are keyed by the normalized name, which the stored meals do not keep, so they are compared one by one
+		storedMeals := make([]webcrawler.Meal, 0)
+		mealCollectionName := config.Get().MealCollectionName
+		if err := persister.ReadAllDocuments(mealCollectionName, c.Request.Context(), &storedMeals); err != nil {
+			NewError(c, http.StatusInternalServerError, err)
+			return
+		}
+		meals := make([]webcrawler.Meal, 0)
+		for _, meal := range storedMeals {
+			if webcrawler.DietOverrideId(meal.Name) == override.Id {
+				meals = append(meals, meal)
+			}
+		}
+		webcrawler.ApplyDietOverrides(meals, []webcrawler.DietOverride{override})
+		// the meals are replaced, so tags that were overridden with none are not kept
+		persister.ReplaceDocuments(mealCollectionName, jobs.ToIdentifiables(meals), c.Request.Context())
+
+		c.JSON(http.StatusOK, override)
+	}
+}
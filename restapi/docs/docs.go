@@ -28,6 +28,280 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/days/{date}": {
+            "get": {
+                "description": "get whether the bistro is open, closed or the menu could not be parsed on the specified date",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "days"
+                ],
+                "summary": "Retrieve the state of a day",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Date in yyyy-mm-dd",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the menu source, defaults to cgm",
+                        "name": "source",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webcrawler.Day"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/dead-letters": {
+            "get": {
+                "description": "get all jobs that used up the attempts of their retry policy, the latest first, requeue them with POST /jobs/{id}/requeue",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letters"
+                ],
+                "summary": "Get all dead-lettered jobs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/jobs.Job"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/diet-overrides": {
+            "get": {
+                "description": "get all manually set diet tags, sorted by meal name",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "diet-overrides"
+                ],
+                "summary": "Get all diet overrides",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webcrawler.DietOverride"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "set the diet tags of all meals with the same name, which take priority over the classified tags\nthe stored meals whose name differs only in case, whitespace, accents or punctuation are updated at once",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "diet-overrides"
+                ],
+                "summary": "Override the diet tags of a meal",
+                "parameters": [
+                    {
+                        "description": "Meal name and its tags (VEGETARIAN, VEGAN, CONTAINS_PORK or CONTAINS_FISH)",
+                        "name": "override",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webcrawler.DietOverride"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webcrawler.DietOverride"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/drifts": {
+            "get": {
+                "description": "get all crawled pages whose layout deviates from the baseline of their source, the latest first",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "drifts"
+                ],
+                "summary": "Get all layout drifts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of the menu source to filter by",
+                        "name": "source",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webcrawler.LayoutDrift"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/images/{id}": {
+            "get": {
+                "description": "get a downloaded meal image or one of its jpeg thumbnails, the widths of the thumbnails are listed on the meal",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif"
+                ],
+                "tags": [
+                    "images"
+                ],
+                "summary": "Retrieve the image of a meal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width of the thumbnail, leave blank for the original image",
+                        "name": "width",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/jobs": {
             "get": {
                 "description": "get job all running jobs",
@@ -69,7 +343,7 @@ var doc = `{
                 }
             },
             "post": {
-                "description": "create a new parser job for the specified date",
+                "description": "create a new parser job for the specified date or for all dates of a range",
                 "consumes": [
                     "plain/text"
                 ],
@@ -82,13 +356,19 @@ var doc = `{
                 "summary": "Create a new parser job",
                 "parameters": [
                     {
-                        "description": "Date to parse in yyyy-mm-dd",
+                        "description": "Date to parse in yyyy-mm-dd or a range of dates in yyyy-mm-dd/yyyy-mm-dd",
                         "name": "date",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the menu source to crawl, defaults to cgm",
+                        "name": "source",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -167,39 +447,269 @@ var doc = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "jobs.Job": {
-            "type": "object",
-            "properties": {
-                "_key": {
-                    "type": "string"
-                },
-                "additional": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
-                "dateToParse": {
-                    "type": "string"
-                },
-                "enqueuedTime": {
-                    "type": "string"
-                },
-                "finishedTime": {
-                    "type": "string"
-                },
-                "id": {
+            },
+            "delete": {
+                "description": "cancel a pending, running or scheduled job, a pending job is removed from the queue and a running job stops fetching and persisting meals",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Cancel a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/requeue": {
+            "post": {
+                "description": "enqueue a failed or dead-lettered job again with all attempts of its retry policy, its previous attempts are kept",
+                "consumes": [
+                    "plain/text"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Requeue a failed job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/restapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "jobs.Job": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "unique identifier for the database",
+                    "type": "string"
+                },
+                "additional": {
+                    "description": "optional information to keep near to the job (e.g. error messages)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "attempts": {
+                    "description": "every finished attempt with its error",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jobs.JobAttempt"
+                    }
+                },
+                "changes": {
+                    "description": "meals that were added, removed or changed since the previous crawl",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.MealChange"
+                    }
+                },
+                "crawledWeeks": {
+                    "description": "the position of the last week that was crawled and persisted",
+                    "type": "integer"
+                },
+                "dateToParse": {
+                    "description": "The date which the parser should parse / has parsed.",
+                    "type": "string"
+                },
+                "drifts": {
+                    "description": "crawled pages whose layout deviates from the baseline",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.LayoutDrift"
+                    }
+                },
+                "enqueuedTime": {
+                    "description": "time the job was enqueued",
+                    "type": "string"
+                },
+                "failedAttempts": {
+                    "description": "the failed attempts since the job was enqueued or requeued",
+                    "type": "integer"
+                },
+                "finishedTime": {
+                    "description": "the time the job has finished the parsing process",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "uuid that unique identifies the job",
+                    "type": "string"
+                },
+                "lastDateToParse": {
+                    "description": "the last date of a range job, blank if the job parses a single week",
+                    "type": "string"
+                },
+                "nextAttemptTime": {
+                    "description": "the time a job with a scheduled retry is enqueued again",
+                    "type": "string"
+                },
+                "parseReport": {
+                    "description": "skipped nodes, warnings and archived snapshots of the parsing",
+                    "type": "object",
+                    "$ref": "#/definitions/webcrawler.ParseReport"
+                },
+                "recoveries": {
+                    "description": "how often the lease of the job expired before it finished",
+                    "type": "integer"
+                },
+                "retryPolicy": {
+                    "description": "how often and when the job is retried after a failure",
+                    "type": "object",
+                    "$ref": "#/definitions/jobs.RetryPolicy"
+                },
+                "source": {
+                    "description": "name of the menu source the job crawls",
                     "type": "string"
                 },
                 "startedTime": {
+                    "description": "the time the job has started the parsing",
                     "type": "string"
                 },
                 "status": {
+                    "description": "PENDING | RUNNING |  SUCCESS | DEGRADED | UNCHANGED | FAILURE | RETRY_SCHEDULED | DEAD_LETTER | CANCELLED",
                     "type": "string"
+                },
+                "totalWeeks": {
+                    "description": "the number of weeks the job crawls",
+                    "type": "integer"
+                }
+            }
+        },
+        "jobs.JobAttempt": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "why the attempt failed",
+                    "type": "string"
+                },
+                "errorClass": {
+                    "description": "the class of the error, which decides about a retry",
+                    "type": "string"
+                },
+                "finishedTime": {
+                    "description": "the time the attempt finished",
+                    "type": "string"
+                },
+                "number": {
+                    "description": "the position of the attempt, starting at 1",
+                    "type": "integer"
+                },
+                "startedTime": {
+                    "description": "the time the attempt started",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "the status the attempt finished with",
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.RetryPolicy": {
+            "type": "object",
+            "properties": {
+                "delayInSeconds": {
+                    "description": "the delay before the first retry",
+                    "type": "integer"
+                },
+                "maxAttempts": {
+                    "description": "attempts including the first one, a job that uses them up is dead-lettered",
+                    "type": "integer"
+                },
+                "maxDelayInSeconds": {
+                    "description": "the longest delay before a retry",
+                    "type": "integer"
+                },
+                "retryableErrorClasses": {
+                    "description": "timeout | network | server | client | robots | parse | other",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
@@ -215,6 +725,420 @@ var doc = `{
                     "example": "status bad request"
                 }
             }
+        },
+        "webcrawler.Day": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "the source and the date, one day is stored per source and date",
+                    "type": "string"
+                },
+                "date": {
+                    "description": "the date of the day",
+                    "type": "string"
+                },
+                "mealCount": {
+                    "description": "the number of offered meals",
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "why the day is closed or unparseable, e.g. the name of the holiday",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "the menu source the day was crawled from",
+                    "type": "string"
+                },
+                "state": {
+                    "description": "OPEN | CLOSED | UNPARSEABLE",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.DietOverride": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "the sha1 hash of the normalized meal name",
+                    "type": "string"
+                },
+                "mealName": {
+                    "description": "the name of the meal",
+                    "type": "string"
+                },
+                "note": {
+                    "description": "why the tags were overridden",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "the diet tags of the meal, which replace the classified tags",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedTime": {
+                    "description": "the time the override was set",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.DietTag": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "description": "from 0 to 1, overridden tags have the confidence 1",
+                    "type": "number"
+                },
+                "overridden": {
+                    "description": "the tag was set manually and not by the classifier",
+                    "type": "boolean"
+                },
+                "tag": {
+                    "description": "VEGETARIAN | VEGAN | CONTAINS_PORK | CONTAINS_FISH",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.LayoutDrift": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "unique identifier for the database",
+                    "type": "string"
+                },
+                "baselineHash": {
+                    "description": "the fingerprint hash of the baseline",
+                    "type": "string"
+                },
+                "detectedTime": {
+                    "description": "the time the drift was detected",
+                    "type": "string"
+                },
+                "hash": {
+                    "description": "the fingerprint hash of the page",
+                    "type": "string"
+                },
+                "jobId": {
+                    "description": "the job whose crawl fetched the page",
+                    "type": "string"
+                },
+                "missingShapes": {
+                    "description": "shapes of the baseline the page does not have",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "newShapes": {
+                    "description": "shapes of the page the baseline does not have",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "profileVersion": {
+                    "description": "the version of the selector profile",
+                    "type": "integer"
+                },
+                "similarity": {
+                    "description": "the share of shapes the page and the baseline have in common",
+                    "type": "number"
+                },
+                "source": {
+                    "description": "the menu source of the page",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.Meal": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "The Id is the identifier of each meal\nit is composed like this: sha1( source + \"-\" + date + normalized name )\nedited names keep the id of the meal they were matched with",
+                    "type": "string"
+                },
+                "additives": {
+                    "description": "canonical additives, e.g. PRESERVATIVE",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "allergens": {
+                    "description": "canonical allergens, e.g. GLUTEN or MILK",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "date": {
+                    "type": "string"
+                },
+                "detailUrl": {
+                    "description": "the page with details about the meal, if the source links one",
+                    "type": "string"
+                },
+                "dietTags": {
+                    "description": "the classified or overridden diets, e.g. VEGETARIAN",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.DietTag"
+                    }
+                },
+                "images": {
+                    "description": "pictures of the meal, if the source shows them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.MealImage"
+                    }
+                },
+                "lowKcal": {
+                    "type": "boolean"
+                },
+                "mandatorySupplements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.Supplement"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nutrition": {
+                    "description": "calories and nutrients, if the source lists them",
+                    "type": "object",
+                    "$ref": "#/definitions/webcrawler.Nutrition"
+                },
+                "optionalSupplements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.Supplement"
+                    }
+                },
+                "price": {
+                    "description": "euros as decimal number, e.g. 3.97, other currencies as object, e.g. {\"amount\": 4.50, \"currency\": \"CHF\"}",
+                    "type": "number"
+                },
+                "source": {
+                    "description": "the menu source the meal was crawled from",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.MealChange": {
+            "type": "object",
+            "properties": {
+                "_key": {
+                    "description": "unique identifier for the database",
+                    "type": "string"
+                },
+                "changedFields": {
+                    "description": "the json names of all changed fields",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "current": {
+                    "description": "the meal as it was crawled now",
+                    "type": "object",
+                    "$ref": "#/definitions/webcrawler.Meal"
+                },
+                "date": {
+                    "description": "the date the meal is offered",
+                    "type": "string"
+                },
+                "detectedTime": {
+                    "description": "the time the change was detected",
+                    "type": "string"
+                },
+                "jobId": {
+                    "description": "the job whose crawl detected the change",
+                    "type": "string"
+                },
+                "mealId": {
+                    "description": "the id of the changed meal",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "the name of the changed meal",
+                    "type": "string"
+                },
+                "previous": {
+                    "description": "the meal as it was stored before",
+                    "type": "object",
+                    "$ref": "#/definitions/webcrawler.Meal"
+                },
+                "priceDelta": {
+                    "description": "the current price minus the previous price, shaped like the price",
+                    "type": "number"
+                },
+                "type": {
+                    "description": "ADDED | REMOVED | CHANGED",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.MealImage": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "description": "the height of the original image in pixels",
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "the sha256 hash of the image, blank if the image was not downloaded",
+                    "type": "string"
+                },
+                "sourceUrl": {
+                    "description": "where the image was found",
+                    "type": "string"
+                },
+                "thumbnails": {
+                    "description": "the widths of the generated thumbnails",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "width": {
+                    "description": "the width of the original image in pixels",
+                    "type": "integer"
+                }
+            }
+        },
+        "webcrawler.Nutrition": {
+            "type": "object",
+            "properties": {
+                "carbohydrates": {
+                    "description": "the carbohydrates in grams",
+                    "type": "number"
+                },
+                "fat": {
+                    "description": "the fat in grams",
+                    "type": "number"
+                },
+                "kcal": {
+                    "description": "the energy in kilocalories",
+                    "type": "number"
+                },
+                "protein": {
+                    "description": "the protein in grams",
+                    "type": "number"
+                }
+            }
+        },
+        "webcrawler.PageFingerprint": {
+            "type": "object",
+            "properties": {
+                "hash": {
+                    "description": "sha1 of all shapes",
+                    "type": "string"
+                },
+                "profileVersion": {
+                    "description": "the version of the selector profile that selected the nodes",
+                    "type": "integer"
+                },
+                "shapes": {
+                    "description": "the distinct dom path shapes, ordered",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "source": {
+                    "description": "the menu source the page was fetched from",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.ParseReport": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "description": "the state of every parsed day",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.Day"
+                    }
+                },
+                "detailPageIds": {
+                    "description": "the archived detail pages that were parsed",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "failedSelector": {
+                    "description": "the selector that aborted the parsing",
+                    "type": "string"
+                },
+                "fingerprints": {
+                    "description": "the layout skeleton of every parsed page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.PageFingerprint"
+                    }
+                },
+                "skippedNodes": {
+                    "description": "nodes that could not be parsed and were left out",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webcrawler.SkippedNode"
+                    }
+                },
+                "snapshotIds": {
+                    "description": "the archived pages that were parsed",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "unchangedWeeks": {
+                    "description": "the weeks whose page was not modified since the last crawl",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "warnings": {
+                    "description": "oddities that did not prevent parsing a node",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "webcrawler.SkippedNode": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "description": "the position of the node within the selection",
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "why the node was skipped",
+                    "type": "string"
+                },
+                "selector": {
+                    "description": "the selector that matched the node",
+                    "type": "string"
+                }
+            }
+        },
+        "webcrawler.Supplement": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "description": "euros as decimal number, other currencies as object with amount and currency",
+                    "type": "number"
+                }
+            }
         }
     }
 }`
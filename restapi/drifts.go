@@ -0,0 +1,47 @@
+package restapi
+
+import (
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+)
+
+// See Declarative Comments Format: https://swaggo.github.io/swaggo.io/declarative_comments_format/general_api_info.html
+
+// driftGet godoc
+// @Summary Get all layout drifts
+// @Description get all crawled pages whose layout deviates from the baseline of their source, the latest first
+// @Tags drifts
+// @Accept plain/text
+// @Produce application/json
+// @Param source query string false "Name of the menu source to filter by"
+// @Success 200 {array} webcrawler.LayoutDrift
+// @Failure 400 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /drifts [get]
+func driftGet() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		drifts := make([]webcrawler.LayoutDrift, 0)
+		collectionName := config.Get().DriftCollectionName
+
+		var err error
+		if sourceName := c.Query("source"); sourceName != "" {
+			err = persister.ReadDocumentsByAttribute(collectionName, "source", []string{sourceName}, c.Request.Context(), &drifts)
+		} else {
+			err = persister.ReadAllDocuments(collectionName, c.Request.Context(), &drifts)
+		}
+		if err != nil {
+			NewError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		sort.SliceStable(drifts, func(i, j int) bool {
+			return drifts[i].DetectedTime > drifts[j].DetectedTime
+		})
+		c.JSON(http.StatusOK, drifts)
+	}
+}
@@ -9,4 +9,4 @@
 ##############
 
 go get -u github.com/swaggo/swag/cmd/swag
-swag init -g server.go
+swag init -g server.go --parseDependency
@@ -0,0 +1,53 @@
+package restapi
+
+import (
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// See Declarative Comments Format: https://swaggo.github.io/swaggo.io/declarative_comments_format/general_api_info.html
+
+// imageGet godoc
+// @Summary Retrieve the image of a meal
+// @Description get a downloaded meal image or one of its jpeg thumbnails, the widths of the thumbnails are listed on the meal
+// @Tags images
+// @Accept plain/text
+// @Produce image/jpeg,image/png,image/gif
+// @Param id path string true "Id of the image"
+// @Param width query int false "Width of the thumbnail, leave blank for the original image"
+// @Success 200 {file} file
+// @Failure 400 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /images/{id} [get]
+func imageGetWithParameter() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		store := webcrawler.DefaultImageStore()
+		if store == nil {
+			NewError(c, http.StatusNotFound, fmt.Errorf("meal images are not stored"))
+			return
+		}
+
+		var width uint64
+		if widthParam := c.Query("width"); widthParam != "" {
+			var err error
+			if width, err = strconv.ParseUint(widthParam, 10, 64); err != nil {
+				NewError(c, http.StatusBadRequest, fmt.Errorf("invalid width, expected a number of pixels but got %s", widthParam))
+				return
+			}
+		}
+
+		imagePath, err := store.Path(c.Param("id"), width)
+		if err != nil {
+			NewError(c, http.StatusNotFound, fmt.Errorf("no image %s found with the width %d", c.Param("id"), width))
+			return
+		}
+
+		// images never change, because they are addressed by their content
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.File(imagePath)
+	}
+}
@@ -1,9 +1,11 @@
 package restapi
 
 import (
+	"errors"
 	"github.com/Rate-My-Bistro/crawler/config"
 	"github.com/Rate-My-Bistro/crawler/jobs"
 	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
@@ -25,7 +27,12 @@ import (
 // @Router /jobs [get]
 func jobGet() func(context *gin.Context) {
 	return func(context *gin.Context) {
-		context.JSON(http.StatusOK, jobs.JobQueue)
+		pendingJobs, err := jobs.PendingJobs(context.Request.Context())
+		if err != nil {
+			NewError(context, http.StatusInternalServerError, err)
+			return
+		}
+		context.JSON(http.StatusOK, pendingJobs)
 	}
 }
 
@@ -52,11 +59,12 @@ func jobGetWithParameter() func(c *gin.Context) {
 
 // jobGet godoc
 // @Summary Create a new parser job
-// @Description create a new parser job for the specified date
+// @Description create a new parser job for the specified date or for all dates of a range
 // @Tags jobs
 // @Produce plain/text
 // @Accept plain/text
-// @Param date body string true "Date to parse in yyyy-mm-dd" format date
+// @Param date body string true "Date to parse in yyyy-mm-dd or a range of dates in yyyy-mm-dd/yyyy-mm-dd"
+// @Param source query string false "Name of the menu source to crawl, defaults to cgm"
 // @Success 201 {string} string
 // @Failure 400 {object} HTTPError
 // @Failure 404 {object} HTTPError
@@ -75,10 +83,68 @@ func jobPost() func(context *gin.Context) {
 	}
 }
 
+// jobRequeue godoc
+// @Summary Requeue a failed job
+// @Description enqueue a failed or dead-lettered job again with all attempts of its retry policy, its previous attempts are kept
+// @Tags jobs
+// @Accept plain/text
+// @Produce application/json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job
+// @Failure 400 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 409 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /jobs/{id}/requeue [post]
+func jobRequeue() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		job, err := jobs.RequeueJob(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, jobs.ErrJobNotFound):
+			NewError(c, http.StatusNotFound, err)
+		case errors.Is(err, jobs.ErrJobNotRequeueable):
+			NewError(c, http.StatusConflict, err)
+		case err != nil:
+			NewError(c, http.StatusInternalServerError, err)
+		default:
+			c.JSON(http.StatusOK, job)
+		}
+	}
+}
+
+// jobDelete godoc
+// @Summary Cancel a job
+// @Description cancel a pending, running or scheduled job, a pending job is removed from the queue and a running job stops fetching and persisting meals
+// @Tags jobs
+// @Accept plain/text
+// @Produce application/json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job
+// @Failure 400 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 409 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /jobs/{id} [delete]
+func jobDelete() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		job, err := jobs.CancelJob(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, jobs.ErrJobNotFound):
+			NewError(c, http.StatusNotFound, err)
+		case errors.Is(err, jobs.ErrJobNotCancellable):
+			NewError(c, http.StatusConflict, err)
+		case err != nil:
+			NewError(c, http.StatusInternalServerError, err)
+		default:
+			c.JSON(http.StatusOK, job)
+		}
+	}
+}
+
 // Define the handler for a GET request with jobId parameter
 func handleGetWithJobIdParameter(c *gin.Context, jobId string) {
 	var job jobs.Job
-	persister.ReadDocumentIfExists(config.Get().JobCollectionName, jobId, &job)
+	persister.ReadDocumentIfExists(config.Get().JobCollectionName, jobId, c.Request.Context(), &job)
 	if job.Id == "" {
 		c.String(404, "No job found for jobId "+jobId)
 	} else {
@@ -98,11 +164,31 @@ func handlePostWithBodyParam(c *gin.Context, dateReader io.ReadCloser) {
 		return
 	}
 
-	_, err := time.Parse("2006-01-02", date)
-	if err == nil {
-		jobId := jobs.EnqueueJob(date)
-		c.String(http.StatusCreated, jobId)
+	// a range of dates is separated by a slash (e.g. '2020-08-01/2020-08-31')
+	dates := strings.SplitN(date, "/", 2)
+	for _, d := range dates {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.String(http.StatusBadRequest, "Invalid date format, expected was 'yyyy-mm-dd' or 'yyyy-mm-dd/yyyy-mm-dd' but got "+date)
+			return
+		}
+	}
+
+	if len(dates) == 2 && dates[1] < dates[0] {
+		c.String(http.StatusBadRequest, "Invalid date range, the end date lies before the start date "+date)
+		return
+	}
+
+	var jobId string
+	var err error
+	sourceName := c.DefaultQuery("source", webcrawler.DefaultSourceName)
+	if len(dates) == 2 {
+		jobId, err = jobs.EnqueueRangeJob(c.Request.Context(), sourceName, dates[0], dates[1])
 	} else {
-		c.String(http.StatusBadRequest, "Invalid date format, expected was 'yyyy-mm-dd' but got "+date)
+		jobId, err = jobs.EnqueueSourceJob(c.Request.Context(), sourceName, date)
+	}
+	if err != nil {
+		NewError(c, http.StatusBadRequest, err)
+		return
 	}
+	c.String(http.StatusCreated, jobId)
 }
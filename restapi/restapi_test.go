@@ -2,12 +2,20 @@ package restapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"github.com/Rate-My-Bistro/crawler/config"
 	"github.com/Rate-My-Bistro/crawler/jobs"
+	"github.com/Rate-My-Bistro/crawler/persister"
+	"github.com/Rate-My-Bistro/crawler/webcrawler"
 	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 )
@@ -29,8 +37,9 @@ func TestAllEndpointsInPositiveCase(t *testing.T) {
 	req, _ = http.NewRequest("GET", "/jobs", nil)
 	router.ServeHTTP(resp, req)
 
+	pendingJobs, _ := jobs.PendingJobs(context.Background())
 	assert.Equal(t, 200, resp.Code)
-	assert.Equal(t, toJsonString(t, jobs.JobQueue), resp.Body.String())
+	assert.Equal(t, toJsonString(t, pendingJobs), resp.Body.String())
 
 	// GET this job by its id
 	resp = httptest.NewRecorder()
@@ -41,7 +50,7 @@ func TestAllEndpointsInPositiveCase(t *testing.T) {
 	assert.Contains(t, resp.Body.String(), jobId)
 
 	// Cleanup
-	jobs.RemoveAllJobs()
+	jobs.RemoveAllJobs(context.Background())
 }
 
 func TestUnknownId(t *testing.T) {
@@ -80,6 +89,42 @@ func TestPostJobWithInvalidDate(t *testing.T) {
 	assert.Equal(t, 400, resp.Code)
 }
 
+func TestPostJobWithDateRange(t *testing.T) {
+	router := setupRouter()
+
+	// When posting a new job for a range of dates
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs", toReader("2020-08-01/2020-08-31"))
+	router.ServeHTTP(resp, req)
+	jobId := resp.Body.String()
+
+	// Then a single job should be enqueued
+	assert.Equal(t, 201, resp.Code)
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/jobs/"+jobId, nil)
+	router.ServeHTTP(resp, req)
+
+	s := toJson(t, resp.Body.String())
+	assert.Equal(t, "2020-08-01", s["dateToParse"])
+	assert.Equal(t, "2020-08-31", s["lastDateToParse"])
+
+	// Cleanup
+	jobs.RemoveAllJobs(context.Background())
+}
+
+func TestPostJobWithInvertedDateRange(t *testing.T) {
+	router := setupRouter()
+
+	// When posting a new job with an end date before its start date
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs", toReader("2020-08-31/2020-08-01"))
+	router.ServeHTTP(resp, req)
+
+	// Then the response should point out the mistake
+	assert.Equal(t, 400, resp.Code)
+}
+
 func TestPostJobWithDateInPast(t *testing.T) {
 	router := setupRouter()
 
@@ -107,6 +152,282 @@ func TestPostJobWithDateInPast(t *testing.T) {
 	assert.Equal(t, s["status"], "FAILURE")
 }
 
+func TestGetDayWithInvalidDate(t *testing.T) {
+	router := setupRouter()
+
+	// When asking for a day with an invalid date
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/days/13-08-2020", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then the response should point out the mistake
+	assert.Equal(t, 400, resp.Code)
+}
+
+func TestGetDayNotCrawled(t *testing.T) {
+	router := setupRouter()
+
+	// When asking for a day that was never crawled
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/days/1900-01-01", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then no day should be found
+	assert.Equal(t, 404, resp.Code)
+}
+
+func TestGetDayOfSource(t *testing.T) {
+	router := setupRouter()
+	day := webcrawler.Day{Id: webcrawler.DayId("cgm", "1900-01-02"), Source: "cgm", Date: "1900-01-02", State: webcrawler.DayClosed}
+	persister.PersistDocument(config.Get().DayCollectionName, day, context.Background())
+	defer persister.RemoveDocuments(config.Get().DayCollectionName, []string{day.Id}, context.Background())
+
+	// When asking for a crawled day of the source
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/days/1900-01-02?source=cgm", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then the day of the source should be returned
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, "CLOSED", toJson(t, resp.Body.String())["state"])
+
+	// When asking for the day of an unknown source
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/days/1900-01-02?source=unknown", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then the response should point out the mistake
+	assert.Equal(t, 400, resp.Code)
+}
+
+func TestGetDrifts(t *testing.T) {
+	router := setupRouter()
+
+	// When asking for the layout drifts of a source
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/drifts?source=unknown", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then an empty list should be returned
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, "[]", resp.Body.String())
+}
+
+func TestGetImage(t *testing.T) {
+	router := setupRouter()
+
+	// When asking for an image that was never stored
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/images/unknown", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should not be found
+	assert.Equal(t, 404, resp.Code)
+}
+
+func TestGetStoredImage(t *testing.T) {
+	router := setupRouter()
+	directory, _ := ioutil.TempDir("", "images")
+	defer os.RemoveAll(directory)
+	store, _ := webcrawler.NewImageStore(directory, []uint64{160}, 0)
+	webcrawler.UseImageStore(store)
+	defer webcrawler.UseImageStore(nil)
+
+	var content bytes.Buffer
+	png.Encode(&content, image.NewRGBA(image.Rect(0, 0, 320, 240)))
+	stored, err := store.Store(content.Bytes())
+	assert.NoError(t, err)
+
+	// When asking for a stored image
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/images/"+stored.Id, nil)
+	router.ServeHTTP(resp, req)
+
+	// Then its bytes should be returned with its content type
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, "image/png", resp.Header().Get("Content-Type"))
+	assert.Equal(t, content.Bytes(), resp.Body.Bytes())
+
+	// When asking for one of its thumbnails
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/images/"+stored.Id+"?width=160", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then the jpeg thumbnail should be returned
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, "image/jpeg", resp.Header().Get("Content-Type"))
+}
+
+func TestPutDietOverride(t *testing.T) {
+	router := setupRouter()
+
+	// When overriding the diet of a meal with an unknown tag
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/diet-overrides", toReader(`{"mealName": "Gemüsecurry", "tags": ["GLUTEN_FREE"]}`))
+	router.ServeHTTP(resp, req)
+
+	// Then the override should be rejected
+	assert.Equal(t, 400, resp.Code)
+
+	// When overriding the diet without a meal name
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/diet-overrides", toReader(`{"tags": ["VEGAN"]}`))
+	router.ServeHTTP(resp, req)
+
+	// Then the override should be rejected
+	assert.Equal(t, 400, resp.Code)
+}
+
+func TestDietOverrideTakesPriority(t *testing.T) {
+	router := setupRouter()
+	ctx := context.Background()
+	meal := webcrawler.Meal{
+		Id:       "diet-override-test",
+		Date:     "1900-01-03",
+		Name:     "Schweineschnitzel mit Pommes",
+		DietTags: []webcrawler.DietTag{{Tag: webcrawler.DietContainsPork, Confidence: 0.9}},
+	}
+	editedMeal := meal
+	editedMeal.Id, editedMeal.Date, editedMeal.Name = "diet-override-edited-test", "1900-01-04", "  schweineschnitzel MIT pommes"
+	persister.PersistDocuments(config.Get().MealCollectionName, jobs.ToIdentifiables([]webcrawler.Meal{meal, editedMeal}), ctx)
+	defer persister.RemoveDocuments(config.Get().MealCollectionName, []string{meal.Id, editedMeal.Id}, ctx)
+	defer persister.RemoveDocuments(config.Get().DietOverrideCollectionName, []string{webcrawler.DietOverrideId(meal.Name)}, ctx)
+
+	// When overriding the classified diet of a stored meal
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/diet-overrides", toReader(`{"mealName": "Schweineschnitzel mit Pommes", "tags": ["VEGETARIAN"]}`))
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+
+	// Then the overridden tags should replace the classified tags
+	var stored webcrawler.Meal
+	persister.ReadDocument(config.Get().MealCollectionName, meal.Id, ctx, &stored)
+	assert.Equal(t, []webcrawler.DietTag{{Tag: webcrawler.DietVegetarian, Confidence: 1, Overridden: true}}, stored.DietTags)
+
+	// And a meal whose name differs only in case and whitespace should get them as well
+	var storedEdited webcrawler.Meal
+	persister.ReadDocument(config.Get().MealCollectionName, editedMeal.Id, ctx, &storedEdited)
+	assert.Equal(t, stored.DietTags, storedEdited.DietTags)
+
+	// And the override should take priority over the classifier for newly crawled meals
+	crawled := []webcrawler.Meal{{Name: meal.Name, DietTags: meal.DietTags}}
+	overrides := make([]webcrawler.DietOverride, 0)
+	persister.ReadDocumentsByAttribute(config.Get().DietOverrideCollectionName, "_key", []string{webcrawler.DietOverrideId(meal.Name)}, ctx, &overrides)
+	webcrawler.ApplyDietOverrides(crawled, overrides)
+	assert.Equal(t, stored.DietTags, crawled[0].DietTags)
+
+	// When overriding the diet of the meal with no tags at all
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/diet-overrides", toReader(`{"mealName": "Schweineschnitzel mit Pommes", "tags": []}`))
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+
+	// Then the stored meal should not keep its former tags
+	var cleared webcrawler.Meal
+	persister.ReadDocument(config.Get().MealCollectionName, meal.Id, ctx, &cleared)
+	assert.Empty(t, cleared.DietTags)
+}
+
+func TestRequeueJob(t *testing.T) {
+	router := setupRouter()
+
+	// When requeuing a job that does not exist
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs/unknown/requeue", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should not be found
+	assert.Equal(t, 404, resp.Code)
+
+	// When asking for the dead-lettered jobs
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/dead-letters", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then they should be listed
+	assert.Equal(t, 200, resp.Code)
+}
+
+func TestRequeueDeadLetterJob(t *testing.T) {
+	router := setupRouter()
+	ctx := context.Background()
+	deadLetter := jobs.Job{
+		Key:            "requeue-test",
+		Id:             "requeue-test",
+		Source:         webcrawler.DefaultSourceName,
+		DateToParse:    "2020-08-13",
+		Status:         "DEAD_LETTER",
+		FailedAttempts: 2,
+		RetryPolicy:    jobs.RetryPolicy{MaxAttempts: 2, RetryableErrorClasses: []string{jobs.ErrorClassNetwork}},
+		Attempts: []jobs.JobAttempt{
+			{Number: 1, Status: "RETRY_SCHEDULED", Error: "connection refused", ErrorClass: jobs.ErrorClassNetwork},
+			{Number: 2, Status: "DEAD_LETTER", Error: "connection refused", ErrorClass: jobs.ErrorClassNetwork},
+		},
+	}
+	persister.PersistDocument(config.Get().JobCollectionName, deadLetter, ctx)
+	defer persister.RemoveDocuments(config.Get().JobCollectionName, []string{deadLetter.Key}, ctx)
+
+	// When requeuing the dead-lettered job
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs/"+deadLetter.Id+"/requeue", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should be pending again with its attempts so far
+	assert.Equal(t, 200, resp.Code)
+	var requeued jobs.Job
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &requeued))
+	assert.Equal(t, "PENDING", requeued.Status)
+	assert.Equal(t, 0, requeued.FailedAttempts)
+	assert.Equal(t, deadLetter.Attempts, requeued.Attempts)
+	jobs.CancelJob(ctx, deadLetter.Id)
+}
+
+func TestDeleteJob(t *testing.T) {
+	router := setupRouter()
+	ctx := context.Background()
+	// the retry is not due during the test, so no worker picks the job up
+	scheduledJob := jobs.Job{
+		Key:             "delete-test",
+		Id:              "delete-test",
+		Source:          webcrawler.DefaultSourceName,
+		DateToParse:     "2020-08-03",
+		Status:          "RETRY_SCHEDULED",
+		NextAttemptTime: time.Now().Add(time.Hour).Format(time.RFC3339),
+		FailedAttempts:  1,
+	}
+	persister.PersistDocument(config.Get().JobCollectionName, scheduledJob, ctx)
+	defer persister.RemoveDocuments(config.Get().JobCollectionName, []string{scheduledJob.Key}, ctx)
+
+	// When cancelling a job that does not exist
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/jobs/unknown", nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should not be found
+	assert.Equal(t, 404, resp.Code)
+
+	// When cancelling a job with a scheduled retry
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/jobs/"+scheduledJob.Id, nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should be cancelled without a retry
+	assert.Equal(t, 200, resp.Code)
+	var cancelled jobs.Job
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &cancelled))
+	assert.Equal(t, "CANCELLED", cancelled.Status)
+	assert.Empty(t, cancelled.NextAttemptTime)
+	assert.NotEmpty(t, cancelled.FinishedTime)
+
+	// When cancelling the cancelled job again
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/jobs/"+scheduledJob.Id, nil)
+	router.ServeHTTP(resp, req)
+
+	// Then it should be rejected
+	assert.Equal(t, 409, resp.Code)
+}
+
 func toReader(s string) io.Reader {
 	return bytes.NewBufferString(s)
 }
@@ -6,6 +6,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 	"log"
+	"net/http"
 	"strconv"
 )
 
@@ -20,10 +21,20 @@ import (
 
 // @host localhost:7331
 
-// configures and starts the http server that serves the rest api
-func Serve() {
-	router := setupRouter()
-	startRouter(router)
+// configures the http server that serves the rest api on the configured port
+func NewServer() *http.Server {
+	portAsString := strconv.FormatUint(config.Get().RestApiPort, 10)
+	return &http.Server{Addr: ":" + portAsString, Handler: setupRouter()}
+}
+
+// starts the http server and blocks until it is shut down
+func Serve(server *http.Server) {
+	log.Println("serving at http://localhost" + server.Addr)
+
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
 
 // adds routes to the server
@@ -32,6 +43,11 @@ func setupRouter() *gin.Engine {
 
 	addApiDocEndpoint(router)
 	addJobsResource(router)
+	addDaysResource(router)
+	addDriftsResource(router)
+	addImagesResource(router)
+	addDietOverridesResource(router)
+	addDeadLettersResource(router)
 
 	return router
 }
@@ -44,6 +60,51 @@ func addJobsResource(router *gin.Engine) {
 		group.GET("/:id", jobGetWithParameter())
 
 		group.POST("", jobPost())
+		group.POST("/:id/requeue", jobRequeue())
+
+		group.DELETE("/:id", jobDelete())
+	}
+}
+
+// Define all routes for this resource
+func addDaysResource(router *gin.Engine) {
+	group := router.Group("/days")
+	{
+		group.GET("/:date", dayGetWithParameter())
+	}
+}
+
+// Define all routes for this resource
+func addDriftsResource(router *gin.Engine) {
+	group := router.Group("/drifts")
+	{
+		group.GET("", driftGet())
+	}
+}
+
+// Define all routes for this resource
+func addImagesResource(router *gin.Engine) {
+	group := router.Group("/images")
+	{
+		group.GET("/:id", imageGetWithParameter())
+	}
+}
+
+// Define all routes for this resource
+func addDietOverridesResource(router *gin.Engine) {
+	group := router.Group("/diet-overrides")
+	{
+		group.GET("", dietOverrideGet())
+
+		group.PUT("", dietOverridePut())
+	}
+}
+
+// Define all routes for this resource
+func addDeadLettersResource(router *gin.Engine) {
+	group := router.Group("/dead-letters")
+	{
+		group.GET("", deadLetterGet())
 	}
 }
 
@@ -56,16 +117,3 @@ func addApiDocEndpoint(router *gin.Engine) {
 	url := ginSwagger.URL("http://localhost:" + restApiPort + "/swagger.json")
 	router.GET("/api/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 }
-
-// starts the http server
-func startRouter(server *gin.Engine) {
-	portAsString := strconv.FormatUint(config.Get().RestApiPort, 10)
-	log.Println("serving at http://localhost:" + portAsString)
-
-	// This method will block the calling goroutine indefinitely unless an error happens.
-	err := server.Run(":" + portAsString)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-}
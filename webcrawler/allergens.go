@@ -0,0 +1,36 @@
+package webcrawler
+
+// Canonical allergens a meal can contain
+// Menu sources map their own allergen codes to these values
+const (
+	AllergenGluten      = "GLUTEN"
+	AllergenCrustaceans = "CRUSTACEANS"
+	AllergenEggs        = "EGGS"
+	AllergenFish        = "FISH"
+	AllergenPeanuts     = "PEANUTS"
+	AllergenSoy         = "SOY"
+	AllergenMilk        = "MILK" // milk including lactose
+	AllergenNuts        = "NUTS"
+	AllergenCelery      = "CELERY"
+	AllergenMustard     = "MUSTARD"
+	AllergenSesame      = "SESAME"
+	AllergenSulphites   = "SULPHITES"
+	AllergenLupin       = "LUPIN"
+	AllergenMolluscs    = "MOLLUSCS"
+)
+
+// Canonical additives a meal can contain
+// Menu sources map their own additive codes to these values
+const (
+	AdditivePreservative    = "PRESERVATIVE"
+	AdditiveColorant        = "COLORANT"
+	AdditiveSulphurated     = "SULPHURATED"
+	AdditiveBlackened       = "BLACKENED"
+	AdditiveAntioxidant     = "ANTIOXIDANT"
+	AdditiveSweetener       = "SWEETENER"
+	AdditiveFlavourEnhancer = "FLAVOUR_ENHANCER"
+	AdditivePhosphate       = "PHOSPHATE"
+	AdditiveNuts            = "NUTS"
+	AdditiveWaxed           = "WAXED"
+	AdditiveNitrite         = "NITRITE"
+)
@@ -0,0 +1,193 @@
+package webcrawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The file extension of archived snapshots
+const snapshotExtension = ".html.gz"
+
+// The sub directory of a source that holds the snapshots of its detail pages
+const detailDirectory = "details"
+
+// Stores fetched pages gzip compressed in a local directory
+// Every page is addressed by the sha256 hash of its content, so an unchanged page is stored only once
+// The snapshots of each menu source are kept in a sub directory named like the source,
+// the detail pages of its meals in a details directory below it
+// Images are not archived, the image store keeps them content-addressed already
+type Archive struct {
+	directory string
+}
+
+// The archive that keeps all crawled pages, nil if archiving is disabled
+var defaultArchive *Archive
+
+func init() {
+	directory := config.Get().ArchiveDirectory
+	if directory == "" {
+		return
+	}
+
+	archive, err := NewArchive(directory)
+	if err != nil {
+		log.Fatal("Failed to create the snapshot archive ", err)
+	}
+	defaultArchive = archive
+}
+
+// Creates a new archive that stores its snapshots in the specified directory
+// The directory is created if it does not exist yet
+func NewArchive(directory string) (*Archive, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("creating the archive directory %s failed: %w", directory, err)
+	}
+	return &Archive{directory: directory}, nil
+}
+
+// Stores a fetched page of the named menu source
+// Returns the id of the snapshot, which is the sha256 hash of the page
+func (archive *Archive) Store(sourceName string, page []byte) (string, error) {
+	return archive.store(filepath.Join(archive.directory, sourceName), page)
+}
+
+// Stores a fetched detail page of the named menu source
+// Detail pages are kept apart from the week pages, so a replay of the source only parses week pages
+// Returns the id of the snapshot, which is the sha256 hash of the page
+func (archive *Archive) StoreDetail(sourceName string, page []byte) (string, error) {
+	return archive.store(filepath.Join(archive.directory, sourceName, detailDirectory), page)
+}
+
+// Stores a page compressed in the directory unless it is stored already
+func (archive *Archive) store(directory string, page []byte) (string, error) {
+	hash := sha256.Sum256(page)
+	snapshotId := hex.EncodeToString(hash[:])
+	snapshotPath := filepath.Join(directory, snapshotId+snapshotExtension)
+
+	if _, err := os.Stat(snapshotPath); err == nil {
+		return snapshotId, nil
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(page); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return snapshotId, writeFileAtomically(snapshotPath, compressed.Bytes())
+}
+
+// Loads the page of a snapshot
+// Returns the uncompressed page or an error if no such snapshot exists
+func (archive *Archive) Load(sourceName string, snapshotId string) ([]byte, error) {
+	return loadSnapshot(archive.snapshotPath(sourceName, snapshotId), snapshotId)
+}
+
+// Loads the detail page of a snapshot
+// Returns the uncompressed page or an error if no such snapshot exists
+func (archive *Archive) LoadDetail(sourceName string, snapshotId string) ([]byte, error) {
+	return loadSnapshot(archive.snapshotPath(filepath.Join(sourceName, detailDirectory), snapshotId), snapshotId)
+}
+
+// Reads and uncompresses the snapshot file
+func loadSnapshot(snapshotPath string, snapshotId string) ([]byte, error) {
+	file, err := os.Open(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("the snapshot %s is corrupted: %w", snapshotId, err)
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+// Lists the ids of all snapshots of the named menu source
+// Returns the ids ordered from the oldest to the newest snapshot
+func (archive *Archive) SnapshotIds(sourceName string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(archive.directory, sourceName))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	snapshotIds := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), snapshotExtension) {
+			snapshotIds = append(snapshotIds, strings.TrimSuffix(file.Name(), snapshotExtension))
+		}
+	}
+	return snapshotIds, nil
+}
+
+// Builds the file path of a snapshot
+func (archive *Archive) snapshotPath(sourceName string, snapshotId string) string {
+	return filepath.Join(archive.directory, sourceName, snapshotId+snapshotExtension)
+}
+
+// Writes the data to a file and creates its directory if needed
+// The data is written to a temporary file of its own first, so no partial file is ever left behind
+// and concurrent writers of the same path never truncate each other's file
+func writeFileAtomically(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	temporaryFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporaryFile.Name())
+
+	_, err = temporaryFile.Write(data)
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temporaryFile.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporaryFile.Name(), path)
+}
+
+// Parses an archived snapshot with the current parser of the named menu source
+// returns the meals found in the snapshot and a report about the parsing
+func Replay(archive *Archive, sourceName string, snapshotId string) (mealDates []Meal, report ParseReport, err error) {
+	source, err := GetSource(sourceName)
+	if err != nil {
+		return nil, report, err
+	}
+
+	page, err := archive.Load(sourceName, snapshotId)
+	if err != nil {
+		return nil, report, err
+	}
+
+	mealDates, report, err = source.ParseWeek(bytes.NewReader(page))
+	report.SnapshotIds = []string{snapshotId}
+	return mealDates, report, err
+}
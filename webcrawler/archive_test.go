@@ -0,0 +1,105 @@
+package webcrawler
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "archive")
+	defer os.RemoveAll(directory)
+	archive, _ := NewArchive(directory)
+	page, _ := ioutil.ReadFile("bistro.html")
+
+	t.Run("expect a stored page to be loaded unchanged", func(t *testing.T) {
+		snapshotId, err := archive.Store(DefaultSourceName, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := archive.Load(DefaultSourceName, snapshotId)
+		if err != nil || string(loaded) != string(page) {
+			t.Fatalf("expected the stored page but got an error or a different page: %v", err)
+		}
+	})
+
+	t.Run("expect the same page to be stored once", func(t *testing.T) {
+		firstId, _ := archive.Store(DefaultSourceName, page)
+		secondId, _ := archive.Store(DefaultSourceName, page)
+		snapshotIds, _ := archive.SnapshotIds(DefaultSourceName)
+		if firstId != secondId || len(snapshotIds) != 1 {
+			t.Fatalf("expected a single snapshot but got %v", snapshotIds)
+		}
+	})
+
+	t.Run("expect detail pages to be kept apart from the week pages", func(t *testing.T) {
+		detailPage := []byte("<html><body>detail</body></html>")
+		snapshotId, err := archive.StoreDetail(DefaultSourceName, detailPage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := archive.LoadDetail(DefaultSourceName, snapshotId)
+		if err != nil || string(loaded) != string(detailPage) {
+			t.Fatalf("expected the stored detail page but got an error or a different page: %v", err)
+		}
+		if snapshotIds, _ := archive.SnapshotIds(DefaultSourceName); len(snapshotIds) != 1 {
+			t.Fatalf("expected only the week page to be replayable but got %v", snapshotIds)
+		}
+	})
+
+	t.Run("expect concurrent stores of the same page to keep it intact", func(t *testing.T) {
+		otherPage := append([]byte("<!-- concurrent -->"), page...)
+		var waitGroup sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				_, err := archive.Store("concurrent", otherPage)
+				errs <- err
+			}()
+		}
+		waitGroup.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		snapshotIds, _ := archive.SnapshotIds("concurrent")
+		loaded, err := archive.Load("concurrent", snapshotIds[0])
+		if err != nil || len(snapshotIds) != 1 || string(loaded) != string(otherPage) {
+			t.Fatalf("expected a single intact snapshot but got %v: %v", snapshotIds, err)
+		}
+	})
+
+	t.Run("expect no snapshots for an unknown source", func(t *testing.T) {
+		snapshotIds, err := archive.SnapshotIds("unknown")
+		if err != nil || len(snapshotIds) != 0 {
+			t.Fatalf("expected no snapshots but got %v: %v", snapshotIds, err)
+		}
+	})
+
+	t.Run("expect a replayed snapshot to be parsed", func(t *testing.T) {
+		snapshotId, _ := archive.Store(DefaultSourceName, page)
+		meals, report, err := Replay(archive, DefaultSourceName, snapshotId)
+		if err != nil || len(meals) != 21 || report.SnapshotIds[0] != snapshotId {
+			t.Fatalf("expected 21 meals of the snapshot %s but got %d: %v", snapshotId, len(meals), err)
+		}
+	})
+
+	t.Run("expect crawled pages to be archived", func(t *testing.T) {
+		defaultArchive = archive
+		defer func() { defaultArchive = nil }()
+
+		_, report, _ := CrawlCurrentWeek(context.Background(), "file://bistro.html")
+		if len(report.SnapshotIds) != 1 {
+			t.Fatalf("expected the crawled page to be archived but got %v", report.SnapshotIds)
+		}
+	})
+}
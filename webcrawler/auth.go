@@ -0,0 +1,218 @@
+package webcrawler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Adds credentials to the requests sent to a host that requires a login
+type Authenticator interface {
+	// Prepares a request before it is sent with the client of the fetcher
+	// The client holds the cookie jar of the fetcher, so logins made with it are kept
+	Authenticate(ctx context.Context, client *http.Client, request *http.Request) error
+}
+
+// Represents an authenticator that needs a setting of the connection, e.g. a client certificate
+type TransportConfigurer interface {
+	Authenticator
+
+	// Changes the transport of the fetcher once the authenticator is used
+	ConfigureTransport(transport *http.Transport) error
+}
+
+// Represents an authenticator that logs in once and keeps a session afterwards
+type SessionAuthenticator interface {
+	Authenticator
+
+	// Tells if the response shows that the session has expired
+	SessionExpired(response *http.Response) bool
+
+	// Discards the session, so the next request logs in again
+	Invalidate()
+}
+
+// Sends a user and password with every request using http basic auth
+type BasicAuthenticator struct {
+	User     string
+	Password string
+}
+
+func (auth *BasicAuthenticator) Authenticate(ctx context.Context, client *http.Client, request *http.Request) error {
+	request.SetBasicAuth(auth.User, auth.Password)
+	return nil
+}
+
+// Logs in by posting a login form and keeps the session cookie in the cookie jar of the fetcher
+// The login is repeated once the session cookie has expired or a response shows that the session has ended
+type FormLoginAuthenticator struct {
+	LoginUrl      string // the url the login form is posted to
+	UserField     string // the form field of the user
+	PasswordField string // the form field of the password
+	User          string
+	Password      string
+	SessionCookie string // the name of the session cookie, blank if any cookie of the login starts the session
+
+	mutex    sync.Mutex
+	loggedIn bool
+}
+
+func (auth *FormLoginAuthenticator) Authenticate(ctx context.Context, client *http.Client, request *http.Request) error {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	if auth.loggedIn && auth.hasSession(client, request.URL) {
+		return nil
+	}
+	return auth.login(ctx, client, request.URL)
+}
+
+// Tells if the cookie jar holds an unexpired session cookie for the url
+func (auth *FormLoginAuthenticator) hasSession(client *http.Client, pageUrl *url.URL) bool {
+	if client.Jar == nil {
+		return false
+	}
+
+	cookies := client.Jar.Cookies(pageUrl)
+	for _, cookie := range cookies {
+		if auth.SessionCookie == "" || cookie.Name == auth.SessionCookie {
+			return true
+		}
+	}
+	return false
+}
+
+// Posts the login form and checks that a session was started for the page url
+func (auth *FormLoginAuthenticator) login(ctx context.Context, client *http.Client, pageUrl *url.URL) error {
+	form := url.Values{}
+	form.Set(auth.UserField, auth.User)
+	form.Set(auth.PasswordField, auth.Password)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.LoginUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	ioutil.ReadAll(response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("the login at %s failed with status %s", auth.LoginUrl, response.Status)
+	}
+	if !auth.hasSession(client, pageUrl) {
+		return fmt.Errorf("the login at %s did not start a session", auth.LoginUrl)
+	}
+
+	auth.loggedIn = true
+	return nil
+}
+
+// A session has expired if the host refuses the request or redirects it to the login page
+func (auth *FormLoginAuthenticator) SessionExpired(response *http.Response) bool {
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return true
+	}
+
+	loginUrl, err := url.Parse(auth.LoginUrl)
+	return err == nil && response.Request != nil &&
+		response.Request.URL.Host == loginUrl.Host && response.Request.URL.Path == loginUrl.Path
+}
+
+func (auth *FormLoginAuthenticator) Invalidate() {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth.loggedIn = false
+}
+
+// Identifies the fetcher with a client certificate during the tls handshake
+type ClientCertificateAuthenticator struct {
+	CertificateFile string // pem encoded certificate
+	KeyFile         string // pem encoded private key of the certificate
+}
+
+func (auth *ClientCertificateAuthenticator) Authenticate(ctx context.Context, client *http.Client, request *http.Request) error {
+	return nil
+}
+
+func (auth *ClientCertificateAuthenticator) ConfigureTransport(transport *http.Transport) error {
+	certificate, err := tls.LoadX509KeyPair(auth.CertificateFile, auth.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading the client certificate %s failed: %w", auth.CertificateFile, err)
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = append(transport.TLSClientConfig.Certificates, certificate)
+	return nil
+}
+
+// Creates the authenticator of the configured auth method
+// Returns nil if no method is configured or an error if the method is unknown or its settings are incomplete
+func newConfiguredAuthenticator(cfg config.Config) (Authenticator, error) {
+	switch cfg.HttpAuthMethod {
+	case "":
+		return nil, nil
+	case "basic":
+		if cfg.HttpAuthUser == "" {
+			return nil, fmt.Errorf("basic auth requires a user")
+		}
+		return &BasicAuthenticator{User: cfg.HttpAuthUser, Password: cfg.HttpAuthPassword}, nil
+	case "form":
+		if cfg.HttpAuthLoginUrl == "" || cfg.HttpAuthUser == "" {
+			return nil, fmt.Errorf("a form login requires a login url and a user")
+		}
+		return &FormLoginAuthenticator{
+			LoginUrl:      cfg.HttpAuthLoginUrl,
+			UserField:     cfg.HttpAuthUserField,
+			PasswordField: cfg.HttpAuthPasswordField,
+			User:          cfg.HttpAuthUser,
+			Password:      cfg.HttpAuthPassword,
+			SessionCookie: cfg.HttpAuthSessionCookie,
+		}, nil
+	case "certificate":
+		if cfg.HttpAuthCertificateFile == "" || cfg.HttpAuthKeyFile == "" {
+			return nil, fmt.Errorf("a client certificate requires a certificate and a key file")
+		}
+		return &ClientCertificateAuthenticator{
+			CertificateFile: cfg.HttpAuthCertificateFile,
+			KeyFile:         cfg.HttpAuthKeyFile,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown http auth method '%s', expected basic, form or certificate", cfg.HttpAuthMethod)
+	}
+}
+
+// Determines the hosts the configured credentials are sent to
+// Without configured hosts the credentials are sent to the hosts of the bistro url and all source locations
+func authHosts(cfg config.Config) []string {
+	if len(cfg.HttpAuthHosts) > 0 {
+		return cfg.HttpAuthHosts
+	}
+
+	locations := []string{cfg.BistroUrl}
+	for _, sourceLocation := range cfg.SourceLocations {
+		if pair := strings.SplitN(sourceLocation, "=", 2); len(pair) == 2 {
+			locations = append(locations, strings.TrimSpace(pair[1]))
+		}
+	}
+
+	hosts := make([]string, 0)
+	for _, location := range locations {
+		if locationUrl, err := url.Parse(location); err == nil && locationUrl.Host != "" {
+			hosts = append(hosts, locationUrl.Host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,151 @@
+package webcrawler
+
+import (
+	"context"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthentication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "bistro" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("menu"))
+	}))
+	defer server.Close()
+
+	cfg := config.Get()
+	cfg.HttpAuthMethod = "basic"
+	cfg.HttpAuthUser = "bistro"
+	cfg.HttpAuthPassword = "secret"
+	cfg.HttpAuthHosts = []string{strings.TrimPrefix(server.URL, "http://")}
+
+	t.Run("expect the credentials to be sent to the configured host", func(t *testing.T) {
+		fetcher, err := NewFetcher(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err := fetcher.Fetch(context.Background(), server.URL); err != nil || string(body) != "menu" {
+			t.Fatalf("expected the menu but got %q: %v", body, err)
+		}
+	})
+
+	t.Run("expect no credentials for other hosts", func(t *testing.T) {
+		cfg.HttpAuthHosts = []string{"bistro.cgm.ag"}
+		fetcher, _ := NewFetcher(cfg)
+		if _, err := fetcher.Fetch(context.Background(), server.URL); err == nil {
+			t.Fatalf("expected the request to be unauthorized")
+		}
+	})
+}
+
+func TestFormLoginAuthentication(t *testing.T) {
+	logins, session := 0, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			r.ParseForm()
+			if r.PostForm.Get("user") != "bistro" || r.PostForm.Get("pass") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins++
+			session = "session-" + strconv.Itoa(logins)
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: session, Path: "/"})
+			return
+		}
+
+		if cookie, err := r.Cookie("PHPSESSID"); err != nil || cookie.Value != session {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("menu"))
+	}))
+	defer server.Close()
+
+	fetcher, _ := NewFetcher(config.Get())
+	authenticator := &FormLoginAuthenticator{
+		LoginUrl:      server.URL + "/login",
+		UserField:     "user",
+		PasswordField: "pass",
+		User:          "bistro",
+		Password:      "secret",
+		SessionCookie: "PHPSESSID",
+	}
+	fetcher.UseAuthenticator(strings.TrimPrefix(server.URL, "http://"), authenticator)
+
+	t.Run("expect a single login for several requests", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if body, err := fetcher.Fetch(context.Background(), server.URL+"/index.php"); err != nil || string(body) != "menu" {
+				t.Fatalf("expected the menu but got %q: %v", body, err)
+			}
+		}
+		if logins != 1 {
+			t.Fatalf("expected a single login but got %d", logins)
+		}
+	})
+
+	t.Run("expect an expired session to be renewed", func(t *testing.T) {
+		session = "ended"
+		if body, err := fetcher.Fetch(context.Background(), server.URL+"/index.php"); err != nil || string(body) != "menu" {
+			t.Fatalf("expected the menu after a new login but got %q: %v", body, err)
+		}
+		if logins != 2 {
+			t.Fatalf("expected a second login but got %d", logins)
+		}
+	})
+
+	t.Run("expect wrong credentials to fail the request", func(t *testing.T) {
+		session = "ended"
+		authenticator.Password = "wrong"
+		if _, err := fetcher.Fetch(context.Background(), server.URL+"/index.php"); err == nil || !strings.Contains(err.Error(), "login") {
+			t.Fatalf("expected a failed login but got %v", err)
+		}
+	})
+}
+
+func TestConfiguredAuthenticator(t *testing.T) {
+
+	t.Run("expect an error for an unknown auth method", func(t *testing.T) {
+		cfg := config.Get()
+		cfg.HttpAuthMethod = "kerberos"
+		if _, err := NewFetcher(cfg); err == nil {
+			t.Fatalf("expected an error for an unknown auth method")
+		}
+	})
+
+	t.Run("expect an error for a missing client certificate", func(t *testing.T) {
+		cfg := config.Get()
+		cfg.HttpAuthMethod = "certificate"
+		cfg.HttpAuthCertificateFile = "missing.pem"
+		cfg.HttpAuthKeyFile = "missing.key"
+		cfg.HttpAuthHosts = []string{"bistro.cgm.ag"}
+		if _, err := NewFetcher(cfg); err == nil {
+			t.Fatalf("expected an error for a missing client certificate")
+		}
+	})
+
+	t.Run("expect an error without hosts for the credentials", func(t *testing.T) {
+		cfg := config.Get()
+		cfg.HttpAuthMethod = "basic"
+		cfg.HttpAuthUser = "bistro"
+		if _, err := NewFetcher(cfg); err == nil {
+			t.Fatalf("expected an error without hosts")
+		}
+	})
+
+	t.Run("expect the hosts of all source locations by default", func(t *testing.T) {
+		cfg := config.Get()
+		cfg.BistroUrl = "https://bistro.cgm.ag/index.php"
+		cfg.SourceLocations = []string{"canteen=https://canteen.example.com:8443/menu"}
+		hosts := authHosts(cfg)
+		if len(hosts) != 2 || hosts[0] != "bistro.cgm.ag" || hosts[1] != "canteen.example.com:8443" {
+			t.Fatalf("expected the hosts of the bistro and the canteen but got %v", hosts)
+		}
+	})
+}
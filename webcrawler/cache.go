@@ -0,0 +1,63 @@
+package webcrawler
+
+import (
+	"sync"
+	"time"
+)
+
+// Keeps the bodies of fetched pages for a while, so repeated requests of a url are not sent again
+type responseCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// Creates a cache that keeps responses for the specified time
+// Returns nil if the time is not positive, which disables caching
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+// Returns the cached body of the url if it has not expired yet
+func (cache *responseCache) get(pageUrl string, now time.Time) ([]byte, bool) {
+	if cache == nil {
+		return nil, false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, found := cache.entries[pageUrl]
+	if !found || now.After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// Caches the body of the url and drops all expired entries
+func (cache *responseCache) put(pageUrl string, body []byte, now time.Time) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for cachedUrl, entry := range cache.entries {
+		if now.After(entry.expires) {
+			delete(cache.entries, cachedUrl)
+		}
+	}
+	cache.entries[pageUrl] = cachedResponse{body: body, expires: now.Add(cache.ttl)}
+}
@@ -0,0 +1,372 @@
+package webcrawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"strings"
+	"time"
+)
+
+// Menu source implementation for the cgm bistro website
+// The selectors of the page nodes come from a selector profile
+type cgmSource struct {
+	profile *SelectorProfile // the profile to parse with, the active profile of the source is used if nil
+}
+
+// Maps the allergen letters of the bistro legend to canonical allergens
+var cgmAllergenCodes = map[string]string{
+	"A": AllergenGluten,
+	"B": AllergenCrustaceans,
+	"C": AllergenEggs,
+	"D": AllergenFish,
+	"E": AllergenPeanuts,
+	"F": AllergenSoy,
+	"G": AllergenMilk,
+	"H": AllergenNuts,
+	"I": AllergenCelery,
+	"J": AllergenMustard,
+	"K": AllergenSesame,
+	"L": AllergenSulphites,
+	"M": AllergenLupin,
+	"N": AllergenMolluscs,
+}
+
+// Maps the additive numbers of the bistro legend to canonical additives
+var cgmAdditiveCodes = map[string]string{
+	"1":  AdditivePreservative,
+	"2":  AdditiveColorant,
+	"3":  AdditiveSulphurated,
+	"4":  AdditiveBlackened,
+	"5":  AdditiveAntioxidant,
+	"6":  AdditiveSweetener,
+	"7":  AdditiveFlavourEnhancer,
+	"8":  AdditivePhosphate,
+	"9":  AdditiveNuts,
+	"10": AdditiveWaxed,
+	"11": AdditiveNitrite,
+}
+
+func init() {
+	RegisterSource(DefaultSourceName, cgmSource{})
+}
+
+// Fetches the bistro page of the week that contains the specified date
+// Specific dates can only be requested from urls, offline locations always contain a single week
+// All dates of a week request the page of its monday, so the page is fetched once for the whole week
+func (source cgmSource) FetchWeek(ctx context.Context, location string, date string) (io.Reader, error) {
+	if date == "" {
+		return createBistroReader(ctx, location)
+	}
+
+	if !strings.HasPrefix(location, "http") {
+		err := fmt.Errorf("specific dates cannot parsed from an offline location only urls are allowed")
+		return nil, err
+	}
+
+	return createBistroReader(ctx, buildDatedBistroLocation(location, weekStart(date)))
+}
+
+// Returns the same source that parses pages with the specified profile
+func (source cgmSource) WithProfile(profile SelectorProfile) MenuSource {
+	return cgmSource{profile: &profile}
+}
+
+// Parses the urls of all images of a meal
+// Lazy loaded images keep their url in the data-src attribute
+func parseImages(mealSelection *goquery.Selection, imageSelector string) []MealImage {
+	var images []MealImage
+	mealSelection.Find(imageSelector).Each(func(i int, imageSelection *goquery.Selection) {
+		source, _ := imageSelection.Attr("data-src")
+		if source == "" {
+			source, _ = imageSelection.Attr("src")
+		}
+		if source = strings.TrimSpace(source); source != "" && !strings.HasPrefix(source, "data:") {
+			images = append(images, MealImage{SourceUrl: source})
+		}
+	})
+	return images
+}
+
+// Parses the nutrition of a meal from its detail page
+func (source cgmSource) ParseDetail(reader io.Reader) (*Nutrition, error) {
+	profile, err := source.selectorProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := requestWebsiteDocument(reader)
+	if err != nil {
+		return nil, err
+	}
+	return parseNutrition(doc.Selection, profile.Selectors.DetailNutrition, profile.Rules.Nutrition), nil
+}
+
+// Parses all meals of the week from a bistro page
+func (source cgmSource) ParseWeek(reader io.Reader) ([]Meal, ParseReport, error) {
+	report := ParseReport{}
+
+	profile, err := source.selectorProfile()
+	if err != nil {
+		return nil, report, err
+	}
+
+	doc, err := requestWebsiteDocument(reader)
+	if err != nil {
+		return nil, report, err
+	}
+	report.Fingerprints = append(report.Fingerprints, fingerprintPage(doc, profile))
+
+	dates, err := parseDates(doc, profile, &report)
+	if err != nil {
+		return nil, report, report.fail(err)
+	}
+
+	meals, err := parseMealsForAllDays(doc, dates, profile, &report)
+	if err != nil {
+		return nil, report, report.fail(err)
+	}
+
+	return meals, report, nil
+}
+
+// Returns the profile the source parses with
+func (source cgmSource) selectorProfile() (*SelectorProfile, error) {
+	if source.profile != nil {
+		return source.profile, nil
+	}
+
+	profile, err := ActiveProfile(DefaultSourceName)
+	return &profile, err
+}
+
+func buildDatedBistroLocation(location string, date string) string {
+	split := strings.Split(date, "-")
+	year := split[0]
+	month := split[1]
+	day := split[2]
+
+	if !strings.HasSuffix(location, "index.php") {
+		location = strings.Replace(location+"/index.php", "//", "/", -1)
+		location = strings.Replace(location, ":/", "://", 1)
+	}
+
+	location = location + fmt.Sprintf("?day=%s&month=%s&year=%s", day, month, year)
+
+	return location
+}
+
+// Parses all meals found in the provided html document
+// Receives a document that holds the bistro website and the dates of its day columns
+// Returns a slice of meals or an error if the page contains no day columns
+func parseMealsForAllDays(doc *goquery.Document, parsedDates []string, profile *SelectorProfile, report *ParseReport) ([]Meal, error) {
+	meals := make([]Meal, 0)
+
+	daySelector := profile.Selectors.Day
+	daySelections := doc.Find(daySelector)
+	if daySelections.Length() == 0 {
+		return nil, newParseError(daySelector, "no day columns found")
+	}
+	if daySelections.Length() != len(parsedDates) {
+		report.addWarning("found %d day columns but %d date headers", daySelections.Length(), len(parsedDates))
+	}
+
+	daySelections.Each(func(i int, daySelection *goquery.Selection) {
+		if i >= len(parsedDates) || parsedDates[i] == "" {
+			report.skipNode(daySelector, i, fmt.Errorf("no date found for the day column"))
+			return
+		}
+
+		date := parsedDates[i]
+		parsedMeals, day := parseMealsForDay(daySelection, fmt.Sprintf("%s[%d] ", daySelector, i), profile, report)
+		day.Id, day.Source, day.Date = DayId(profile.Source, date), profile.Source, date
+		report.Days = append(report.Days, day)
+
+		for _, meal := range parsedMeals {
+			meal.Source, meal.Date = profile.Source, date
+			meal.Id = MealId(meal.Source, meal.Date, meal.Name)
+			meals = append(meals, meal)
+		}
+	})
+
+	return meals, nil
+}
+
+// parses all meals for a given day
+// receives a selector that holds the meal data of a single day and the selector path of the day for reporting
+// returns a set of meals and the state of the day, meals that cannot be parsed are skipped
+func parseMealsForDay(daySelection *goquery.Selection, dayPath string, profile *SelectorProfile, report *ParseReport) ([]Meal, Day) {
+	var meals []Meal
+	var closingReasons []string
+
+	mealSelections := daySelection.Find(profile.Selectors.Meal)
+	mealSelections.Each(func(i int, mealSelection *goquery.Selection) {
+		meal, err := parseMeal(mealSelection, profile, report)
+		if err != nil {
+			report.skipNode(dayPath+profile.Selectors.Meal, i, err)
+			return
+		}
+
+		// days without meals (e.g. holidays) list the reason as meal without price
+		if meal.Price.Cents > 0 {
+			meals = append(meals, meal)
+		} else {
+			closingReasons = append(closingReasons, meal.Name)
+		}
+	})
+
+	day := Day{State: DayOpen, MealCount: len(meals)}
+	if len(meals) == 0 && len(closingReasons) > 0 {
+		day.State, day.Reason = DayClosed, strings.Join(closingReasons, ", ")
+	} else if mealSelections.Length() == 0 {
+		day.State, day.Reason = DayUnparseable, "no meals found"
+	} else if len(meals) == 0 {
+		day.State, day.Reason = DayUnparseable, fmt.Sprintf("none of the %d meals could be parsed", mealSelections.Length())
+	} else {
+		for _, name := range closingReasons {
+			report.addWarning("%sthe meal '%s' of an open day has no price", dayPath, name)
+		}
+	}
+
+	return meals, day
+}
+
+// parses a single meal
+// receives a selector that holds the data of one meal
+// returns the meal or an error if its name or price cannot be parsed
+func parseMeal(mealSelection *goquery.Selection, profile *SelectorProfile, report *ParseReport) (meal Meal, err error) {
+	meal.Name = cleanName(mealSelection.Find(profile.Selectors.MealName).Text())
+	if meal.Name == "" {
+		return meal, fmt.Errorf("the meal has no name")
+	}
+
+	// a blank price marks an entry that is no meal, e.g. the name of a holiday
+	if priceString := strings.TrimSpace(mealSelection.Find(profile.Selectors.MealPrice).Text()); priceString != "" {
+		if meal.Price, err = ParseMoney(priceString); err != nil {
+			return meal, err
+		}
+	}
+
+	rules := profile.Rules
+	meal.LowKcal = containsAttributeValue(mealSelection, rules.LowKcal.Attribute, rules.LowKcal.Contains)
+	meal.MandatorySupplements = parseMandatorySupplements(mealSelection, profile.Selectors.MandatorySupplement)
+	meal.OptionalSupplements = parseOptionalSupplements(mealSelection, profile, report)
+	meal.Additives = parseLabelCodes(mealSelection, profile.Selectors.Label, rules.AdditiveLabel, cgmAdditiveCodes, report)
+	meal.Allergens = parseLabelCodes(mealSelection, profile.Selectors.Label, rules.AllergenLabel, cgmAllergenCodes, report)
+	meal.Nutrition = parseNutrition(mealSelection, profile.Selectors.Nutrition, rules.Nutrition)
+	if profile.Selectors.DetailLink != "" {
+		meal.DetailUrl, _ = mealSelection.Find(profile.Selectors.DetailLink).Attr("href")
+	}
+	if profile.Selectors.Image != "" {
+		meal.Images = parseImages(mealSelection, profile.Selectors.Image)
+	}
+
+	return meal, nil
+}
+
+func parseMandatorySupplements(mealSelection *goquery.Selection, supplementSelector string) (mandatorySupplements []Supplement) {
+	mandatorySupplementName := strings.TrimSpace(mealSelection.Find(supplementSelector).Text())
+	if mandatorySupplementName != "" {
+		mandatorySupplements = append(mandatorySupplements, Supplement{
+			Name:  mandatorySupplementName,
+			Price: EuroCents(0),
+		})
+	}
+	return mandatorySupplements
+}
+
+// Parses a comma separated list of codes that follows the specified label (e.g. 'Allergen: A, C')
+// Receives a queryable meal selection, the selector of the label nodes and a mapping from the codes of the page to canonical values
+// Returns the canonical values, unknown codes are skipped with a warning
+func parseLabelCodes(mealSelection *goquery.Selection, labelSelector string, label string, codes map[string]string, report *ParseReport) (values []string) {
+	mealSelection.Find(labelSelector).Each(func(i int, labelSelection *goquery.Selection) {
+		text := strings.TrimSpace(labelSelection.Text())
+		if labelSelection.Children().Length() > 0 || !strings.HasPrefix(text, label) {
+			return
+		}
+
+		for _, code := range strings.Split(strings.TrimPrefix(text, label), ",") {
+			code = strings.TrimSpace(code)
+			if value, known := codes[code]; known {
+				values = append(values, value)
+			} else if code != "" {
+				report.addWarning("skipped unknown code '%s' after label '%s'", code, label)
+			}
+		}
+	})
+
+	return values
+}
+
+// Parses all dates of the week
+// Receives a queryable html document
+// Returns a set of string dates in the format: yyyy-mm-dd
+// Dates that cannot be parsed are reported and left blank to keep the positions of the day columns
+func parseDates(doc *goquery.Document, profile *SelectorProfile, report *ParseReport) ([]string, error) {
+	parsedDates := make([]string, 0)
+
+	dateSelector := profile.Selectors.Date
+	dateSelections := doc.Find(dateSelector)
+	if dateSelections.Length() == 0 {
+		return nil, newParseError(dateSelector, "no date headers found")
+	}
+
+	dateSelections.Each(func(i int, dateSelection *goquery.Selection) {
+		parsedDate, err := parseDate(dateSelection, profile.Rules.DateLayout)
+		if err != nil {
+			report.skipNode(dateSelector, i, err)
+		}
+		parsedDates = append(parsedDates, parsedDate)
+	})
+	return parsedDates, nil
+}
+
+// Parses the date of a single date header
+// Receives a date header selection whose last text holds a date in the specified layout, e.g. '31.12.2020'
+// Returns the date in the format: yyyy-mm-dd
+func parseDate(dateSelection *goquery.Selection, layout string) (string, error) {
+	lastChild := dateSelection.Nodes[0].LastChild
+	if lastChild == nil {
+		return "", fmt.Errorf("the date header is empty")
+	}
+
+	parsedDate, err := time.Parse(layout, strings.TrimSpace(lastChild.Data))
+	if err != nil {
+		return "", err
+	}
+	return parsedDate.Format("2006-01-02"), nil
+}
+
+// Parses supplements of a given meal selection
+// Receives a queryable meal selection
+// Returns a set of supplements or an empty slice if no supplements found for a meal
+func parseOptionalSupplements(mealSelection *goquery.Selection, profile *SelectorProfile, report *ParseReport) (optionalSupplements []Supplement) {
+	supplementSelector := profile.Selectors.OptionalSupplement
+	mealSelection.Find(supplementSelector).Each(func(i int, supplementSelection *goquery.Selection) {
+		optionalSupplement, err := parseOptionalSupplement(supplementSelection, profile.Selectors.OptionalSupplementColumn)
+		if err != nil {
+			report.skipNode(supplementSelector, i, err)
+			return
+		}
+
+		optionalSupplements = append(optionalSupplements, optionalSupplement)
+	})
+
+	return optionalSupplements
+}
+
+// Parses a single supplement
+// Receives a supplement selection that holds a name and a price column and the selector of the columns
+// Returns the supplement or an error if a column is missing
+func parseOptionalSupplement(supplementSelection *goquery.Selection, columnSelector string) (optionalSupplement Supplement, err error) {
+	columns := supplementSelection.Find(columnSelector).Nodes
+	if len(columns) < 2 || columns[0].FirstChild == nil || columns[1].FirstChild == nil {
+		return optionalSupplement, fmt.Errorf("expected a name and a price column but found %d columns", len(columns))
+	}
+
+	optionalSupplement.Name = strings.TrimSpace(columns[0].FirstChild.Data)
+	optionalSupplement.Price, err = ParseMoney(columns[1].FirstChild.Data)
+
+	return optionalSupplement, err
+}
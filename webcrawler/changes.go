@@ -0,0 +1,150 @@
+package webcrawler
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Types of changes between two crawls of the same date
+const (
+	ChangeAdded   = "ADDED"   // the meal was not offered before
+	ChangeRemoved = "REMOVED" // the meal is not offered anymore
+	ChangeChanged = "CHANGED" // the meal is still offered with different details
+)
+
+// Represents the change of a single meal between two crawls of the same date
+type MealChange struct {
+	Id            string   `json:"_key,omitempty"`                  // unique identifier for the database
+	JobId         string   `json:"jobId,omitempty"`                 // the job whose crawl detected the change
+	DetectedTime  string   `json:"detectedTime,omitempty"`          // the time the change was detected
+	Type          string   `json:"type"`                            // ADDED | REMOVED | CHANGED
+	Date          string   `json:"date"`                            // the date the meal is offered
+	MealId        string   `json:"mealId"`                          // the id of the changed meal
+	Name          string   `json:"name"`                            // the name of the changed meal
+	ChangedFields []string `json:"changedFields,omitempty"`         // the json names of all changed fields
+	PriceDelta    Money    `json:"priceDelta" swaggertype:"number"` // the current price minus the previous price, shaped like the price
+	Previous      *Meal    `json:"previous,omitempty"`              // the meal as it was stored before
+	Current       *Meal    `json:"current,omitempty"`               // the meal as it was crawled now
+}
+
+func (change MealChange) GetId() string {
+	return change.Id
+}
+
+// Compares the previously stored meals with freshly crawled meals
+// Only dates that have previous meals are compared, the first crawl of a date is no change
+// Returns all added, removed and changed meals ordered by date and name
+func DetectChanges(previousMeals []Meal, currentMeals []Meal) []MealChange {
+	changes := make([]MealChange, 0)
+
+	previousDates := make(map[string]bool)
+	previousById := make(map[string]Meal)
+	for _, meal := range previousMeals {
+		previousDates[meal.Date] = true
+		previousById[meal.Id] = meal
+	}
+
+	currentDates := make(map[string]bool)
+	currentById := make(map[string]Meal)
+	for _, meal := range currentMeals {
+		currentDates[meal.Date] = true
+		currentById[meal.Id] = meal
+	}
+
+	for _, current := range currentMeals {
+		if !previousDates[current.Date] {
+			continue
+		}
+
+		current := current
+		previous, exists := previousById[current.Id]
+		if !exists {
+			changes = append(changes, newMealChange(ChangeAdded, nil, &current))
+		} else if changedFields := compareMeals(previous, current); len(changedFields) > 0 {
+			change := newMealChange(ChangeChanged, &previous, &current)
+			change.ChangedFields = changedFields
+			changes = append(changes, change)
+		}
+	}
+
+	for _, previous := range previousMeals {
+		previous := previous
+		if _, exists := currentById[previous.Id]; !exists && currentDates[previous.Date] {
+			changes = append(changes, newMealChange(ChangeRemoved, &previous, nil))
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Date != changes[j].Date {
+			return changes[i].Date < changes[j].Date
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// Creates a change between two versions of a meal, one of them may be nil
+func newMealChange(changeType string, previous *Meal, current *Meal) MealChange {
+	change := MealChange{
+		Type:     changeType,
+		Previous: previous,
+		Current:  current,
+	}
+
+	var previousPrice, currentPrice Money
+	if previous != nil {
+		change.Date, change.MealId, change.Name = previous.Date, previous.Id, previous.Name
+		previousPrice = previous.Price
+	}
+	if current != nil {
+		change.Date, change.MealId, change.Name = current.Date, current.Id, current.Name
+		currentPrice = current.Price
+	}
+	change.PriceDelta = currentPrice.Sub(previousPrice)
+
+	return change
+}
+
+// Compares the details of two versions of the same meal
+// The identity of a meal, its id, source and date, is not compared
+// Returns the json names of all fields that differ
+func compareMeals(previous Meal, current Meal) []string {
+	var changedFields []string
+
+	previousValue := reflect.ValueOf(previous)
+	currentValue := reflect.ValueOf(current)
+	mealType := previousValue.Type()
+
+	for i := 0; i < mealType.NumField(); i++ {
+		field := mealType.Field(i)
+		if field.Name == "Id" || field.Name == "Source" || field.Name == "Date" {
+			continue
+		}
+
+		if !equalValues(previousValue.Field(i), currentValue.Field(i)) {
+			changedFields = append(changedFields, jsonName(field))
+		}
+	}
+
+	return changedFields
+}
+
+// Compares two field values deeply
+// Empty and missing slices are equal, because stored documents do not keep the difference
+func equalValues(previous reflect.Value, current reflect.Value) bool {
+	if previous.Kind() == reflect.Slice && previous.Len() == 0 && current.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(previous.Interface(), current.Interface())
+}
+
+// Determines the json name of a struct field
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
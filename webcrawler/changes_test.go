@@ -0,0 +1,61 @@
+package webcrawler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectChanges(t *testing.T) {
+	soup := Meal{Id: "soup", Date: "2020-06-08", Name: "Suppe", Price: EuroCents(310)}
+	pasta := Meal{Id: "pasta", Date: "2020-06-08", Name: "Nudeln", Price: EuroCents(420)}
+	pizza := Meal{Id: "pizza", Date: "2020-06-08", Name: "Pizza", Price: EuroCents(530)}
+	salad := Meal{Id: "salad", Date: "2020-06-09", Name: "Salat", Price: EuroCents(620)}
+
+	t.Run("expect no changes for the same meals", func(t *testing.T) {
+		changes := DetectChanges([]Meal{soup, pasta}, []Meal{soup, pasta})
+		if len(changes) != 0 {
+			t.Fatalf("expected no changes but got %+v", changes)
+		}
+	})
+
+	t.Run("expect no changes for the first crawl of a date", func(t *testing.T) {
+		changes := DetectChanges([]Meal{soup}, []Meal{soup, salad})
+		if len(changes) != 0 {
+			t.Fatalf("expected no changes but got %+v", changes)
+		}
+	})
+
+	t.Run("expect a swapped meal as removed and added", func(t *testing.T) {
+		changes := DetectChanges([]Meal{soup, pasta}, []Meal{soup, pizza})
+		if len(changes) != 2 {
+			t.Fatalf("expected 2 changes but got %+v", changes)
+		}
+		if changes[0].Type != ChangeRemoved || changes[0].MealId != pasta.Id || changes[0].PriceDelta != EuroCents(-420) {
+			t.Fatalf("expected the pasta to be removed but got %+v", changes[0])
+		}
+		if changes[1].Type != ChangeAdded || changes[1].MealId != pizza.Id || changes[1].PriceDelta != EuroCents(530) {
+			t.Fatalf("expected the pizza to be added but got %+v", changes[1])
+		}
+	})
+
+	t.Run("expect a corrected price as change with its delta", func(t *testing.T) {
+		correctedSoup := soup
+		correctedSoup.Price = EuroCents(330)
+		changes := DetectChanges([]Meal{soup}, []Meal{correctedSoup})
+		if len(changes) != 1 || changes[0].Type != ChangeChanged || changes[0].PriceDelta != EuroCents(20) {
+			t.Fatalf("expected a price change of 0.20 but got %+v", changes)
+		}
+		if !reflect.DeepEqual(changes[0].ChangedFields, []string{"price"}) {
+			t.Fatalf("expected only the price to be changed but got %v", changes[0].ChangedFields)
+		}
+	})
+
+	t.Run("expect empty and missing slices to be equal", func(t *testing.T) {
+		storedSoup := soup
+		storedSoup.Allergens = []string{}
+		changes := DetectChanges([]Meal{storedSoup}, []Meal{soup})
+		if len(changes) != 0 {
+			t.Fatalf("expected no changes but got %+v", changes)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package webcrawler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The date format used by all crawling functions
+const dateLayout = "2006-01-02"
+
+// Crawls all meals between two dates from the cgm bistro website
+// Both dates are inclusive and must have the format 'yyyy-mm-dd'
+// returns a slice of meals within the range and the combined report of all crawled weeks
+func CrawlRange(ctx context.Context, bistroLocation string, from string, to string) (mealDates []Meal, report ParseReport, err error) {
+	return CrawlSourceRange(ctx, DefaultSourceName, bistroLocation, from, to)
+}
+
+// Crawls all meals between two dates from the named menu source
+// Every week within the range is fetched only once, meals outside the range are dropped
+// Weeks whose page was not modified since the last crawl are left out and listed in the report
+// returns a slice of meals within the range and the combined report of all crawled weeks
+// or ErrNotModified if no page of the range was modified
+// Use StreamSourceRange to process the weeks before the whole range is crawled
+func CrawlSourceRange(ctx context.Context, sourceName string, location string, from string, to string) (mealDates []Meal, report ParseReport, err error) {
+	mealDates = make([]Meal, 0)
+	report, err = StreamSourceRange(ctx, sourceName, location, from, to, func(ctx context.Context, batch WeekBatch) error {
+		mealDates = append(mealDates, batch.Meals...)
+		return nil
+	})
+	return mealDates, report, err
+}
+
+// Determines one date for every distinct ISO week between two dates
+// Both dates are inclusive and must have the format 'yyyy-mm-dd'
+// returns the first date of the range that lies in each week
+func weekDates(from string, to string) ([]string, error) {
+	fromDate, err := time.Parse(dateLayout, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date '%s', expected the format yyyy-mm-dd", from)
+	}
+
+	toDate, err := time.Parse(dateLayout, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date '%s', expected the format yyyy-mm-dd", to)
+	}
+
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("the end date %s lies before the start date %s", to, from)
+	}
+
+	dates := make([]string, 0)
+	lastYear, lastWeek := 0, 0
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		year, week := date.ISOWeek()
+		if year != lastYear || week != lastWeek {
+			dates = append(dates, date.Format(dateLayout))
+			lastYear, lastWeek = year, week
+		}
+	}
+
+	return dates, nil
+}
+
+// Filters the days that lie between the first and the last date, both inclusive
+func daysWithin(days []Day, from string, to string) []Day {
+	daysInRange := make([]Day, 0, len(days))
+	for _, day := range days {
+		if day.Date >= from && day.Date <= to {
+			daysInRange = append(daysInRange, day)
+		}
+	}
+	return daysInRange
+}
+
+// Returns the monday of the week that contains the date
+// Dates that cannot be parsed are returned unchanged
+func weekStart(date string) string {
+	parsedDate, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return date
+	}
+
+	// go weeks start on sunday, iso weeks on monday
+	daysSinceMonday := (int(parsedDate.Weekday()) + 6) % 7
+	return parsedDate.AddDate(0, 0, -daysSinceMonday).Format(dateLayout)
+}
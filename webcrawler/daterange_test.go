@@ -0,0 +1,119 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWeekDates(t *testing.T) {
+
+	t.Run("expect one date per week", func(t *testing.T) {
+		want := []string{"2020-06-10", "2020-06-15", "2020-06-22"}
+		got, _ := weekDates("2020-06-10", "2020-06-23")
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the dates %v but got %v", want, got)
+		}
+	})
+
+	t.Run("expect iso weeks across the turn of the year", func(t *testing.T) {
+		want := []string{"2020-12-31", "2021-01-04"}
+		got, _ := weekDates("2020-12-31", "2021-01-04")
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the dates %v but got %v", want, got)
+		}
+	})
+
+	t.Run("expect an error for an end date before the start date", func(t *testing.T) {
+		if _, err := weekDates("2020-06-10", "2020-06-09"); err == nil {
+			t.Fatalf("expected an error for an inverted range")
+		}
+	})
+
+	t.Run("expect an error for an invalid date", func(t *testing.T) {
+		if _, err := weekDates("2020-06-10", "10.06.2020"); err == nil {
+			t.Fatalf("expected an error for an invalid date")
+		}
+	})
+}
+
+func TestWeekStart(t *testing.T) {
+
+	t.Run("expect the monday of the week", func(t *testing.T) {
+		for _, date := range []string{"2020-06-08", "2020-06-11", "2020-06-14"} {
+			if got := weekStart(date); got != "2020-06-08" {
+				t.Fatalf("expected the monday 2020-06-08 for %s but got %s", date, got)
+			}
+		}
+	})
+
+	t.Run("expect the monday of the previous year", func(t *testing.T) {
+		if got := weekStart("2021-01-01"); got != "2020-12-28" {
+			t.Fatalf("expected the monday 2020-12-28 but got %s", got)
+		}
+	})
+}
+
+func TestCrawlRange(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeFile(w, r, "bistro.html")
+	}))
+	defer server.Close()
+
+	t.Run("expect a single request for dates of the same week", func(t *testing.T) {
+		requests = 0
+		got, _, err := CrawlRange(context.Background(), server.URL, "2020-06-09", "2020-06-11")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requests != 1 {
+			t.Fatalf("expected 1 request but got %d", requests)
+		}
+		if len(got) != 10 {
+			t.Fatalf("expected 10 meals but got %d", len(got))
+		}
+	})
+
+	t.Run("expect meals outside the range to be dropped", func(t *testing.T) {
+		requests = 0
+		ForgetPageValidators()
+		got, report, _ := CrawlRange(context.Background(), server.URL, "2020-06-12", "2020-06-15")
+		if requests != 2 {
+			t.Fatalf("expected 2 requests but got %d", requests)
+		}
+		for _, day := range report.Days {
+			if day.Date < "2020-06-12" || day.Date > "2020-06-15" {
+				t.Fatalf("expected only days of the range but got %s", day.Date)
+			}
+		}
+		for _, meal := range got {
+			if meal.Date != "2020-06-12" {
+				t.Fatalf("expected only meals of 2020-06-12 but got one of %s", meal.Date)
+			}
+		}
+	})
+
+	t.Run("expect weeks that were not modified to be skipped", func(t *testing.T) {
+		requests = 0
+		got, report, err := CrawlRange(context.Background(), server.URL, "2020-06-12", "2020-06-15")
+		if !errors.Is(err, ErrNotModified) || len(got) != 0 || requests != 2 {
+			t.Fatalf("expected no meals after 2 requests but got %v, %d meals after %d requests", err, len(got), requests)
+		}
+		if len(report.UnchangedWeeks) != 2 || len(report.Fingerprints) != 0 {
+			t.Fatalf("expected 2 unchanged weeks without parsing but got %+v", report)
+		}
+	})
+
+	t.Run("expect a cancelled context to stop the crawling", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, _, err := CrawlRange(ctx, server.URL, "2020-06-12", "2020-06-15"); err != context.Canceled {
+			t.Fatalf("expected a cancellation error but got %v", err)
+		}
+	})
+}
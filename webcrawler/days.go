@@ -0,0 +1,34 @@
+package webcrawler
+
+// States of a single day of a crawled menu
+const (
+	DayOpen        = "OPEN"        // the bistro offers meals
+	DayClosed      = "CLOSED"      // the bistro is closed, e.g. on a holiday
+	DayUnparseable = "UNPARSEABLE" // the menu of the day could not be parsed
+)
+
+// Represents the result of crawling the menu of a single day
+// A closed day is recorded explicitly, so it can be told apart from a failed crawl
+type Day struct {
+	Id        string `json:"_key,omitempty"`   // the source and the date, one day is stored per source and date
+	Source    string `json:"source"`           // the menu source the day was crawled from
+	Date      string `json:"date"`             // the date of the day
+	State     string `json:"state"`            // OPEN | CLOSED | UNPARSEABLE
+	Reason    string `json:"reason,omitempty"` // why the day is closed or unparseable, e.g. the name of the holiday
+	MealCount int    `json:"mealCount"`        // the number of offered meals
+}
+
+// Builds the id of the day of a menu source at a date, e.g. cgm-2020-06-08
+// Every source has days of its own, so sources crawling the same date do not overwrite each other
+func DayId(sourceName string, date string) string {
+	return sourceName + "-" + date
+}
+
+func (day Day) GetId() string {
+	return day.Id
+}
+
+// Tests if the bistro offers meals on this day
+func (day Day) IsOpen() bool {
+	return day.State == DayOpen
+}
@@ -0,0 +1,221 @@
+package webcrawler
+
+import (
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Canonical diet tags of a meal
+const (
+	DietVegetarian   = "VEGETARIAN"
+	DietVegan        = "VEGAN"
+	DietContainsPork = "CONTAINS_PORK"
+	DietContainsFish = "CONTAINS_FISH"
+)
+
+// Confidence of the classifier in a tag, depending on where the evidence was found
+const (
+	confidenceLabel      = 0.95 // the meal is explicitly labelled, e.g. 'vegetarisch'
+	confidenceName       = 0.9  // a keyword was found in the name
+	confidenceSupplement = 0.75 // a keyword was only found in a supplement
+	confidenceAbsence    = 0.6  // no meat or fish keyword was found
+	confidenceVegan      = 0.5  // neither meat nor animal products were found in the texts and allergens
+	confidenceOverride   = 1.0  // the tag was set manually
+)
+
+// Represents the diet a meal suits or an ingredient it contains, together with the certainty of the classification
+type DietTag struct {
+	Tag        string  `json:"tag"`                  // VEGETARIAN | VEGAN | CONTAINS_PORK | CONTAINS_FISH
+	Confidence float64 `json:"confidence"`           // from 0 to 1, overridden tags have the confidence 1
+	Overridden bool    `json:"overridden,omitempty"` // the tag was set manually and not by the classifier
+}
+
+// Represents the manually set diet tags of a meal, which take priority over the classified tags
+// An override applies to every meal with the same normalized name, regardless of its date
+type DietOverride struct {
+	Id          string   `json:"_key,omitempty"` // the sha1 hash of the normalized meal name
+	MealName    string   `json:"mealName"`       // the name of the meal
+	Tags        []string `json:"tags"`           // the diet tags of the meal, which replace the classified tags
+	Note        string   `json:"note,omitempty"` // why the tags were overridden
+	UpdatedTime string   `json:"updatedTime"`    // the time the override was set
+}
+
+func (override DietOverride) GetId() string {
+	return override.Id
+}
+
+// Holds the keywords the diet classifier looks for
+type DietLexicon struct {
+	Version        int          `yaml:"version"`
+	Meat           KeywordGroup `yaml:"meat"`           // meat of any kind
+	Pork           KeywordGroup `yaml:"pork"`           // pork, which is meat as well
+	Fish           KeywordGroup `yaml:"fish"`           // fish and seafood
+	AnimalProducts KeywordGroup `yaml:"animalProducts"` // products vegetarian meals may contain but vegan meals may not
+	Vegetarian     KeywordGroup `yaml:"vegetarian"`     // explicit labels of vegetarian meals
+	Vegan          KeywordGroup `yaml:"vegan"`          // explicit labels of vegan meals
+}
+
+// Holds keywords that match anywhere within a word and keywords that only match whole words
+type KeywordGroup struct {
+	Contains []string `yaml:"contains"`
+	Words    []string `yaml:"words"`
+}
+
+// The lexicon all meals are classified with
+var dietLexicon DietLexicon
+
+// Loads the keyword lexicon of the diet classifier from the configured file
+func init() {
+	lexicon, err := LoadDietLexicon(config.Get().DietLexiconFile)
+	if err != nil {
+		log.Fatal("Failed to load the diet lexicon ", err)
+	}
+	dietLexicon = lexicon
+}
+
+// Loads a keyword lexicon of the diet classifier from a yaml file
+func LoadDietLexicon(file string) (DietLexicon, error) {
+	var lexicon DietLexicon
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return lexicon, err
+	}
+	if err := yaml.UnmarshalStrict(content, &lexicon); err != nil {
+		return lexicon, fmt.Errorf("reading the diet lexicon %s failed: %w", file, err)
+	}
+	return lexicon, nil
+}
+
+// Tells if the tag is one of the canonical diet tags
+func IsDietTag(tag string) bool {
+	switch tag {
+	case DietVegetarian, DietVegan, DietContainsPork, DietContainsFish:
+		return true
+	}
+	return false
+}
+
+// Classifies all meals with the configured lexicon
+func classifyMeals(meals []Meal) {
+	for i := range meals {
+		meals[i].DietTags = dietLexicon.Classify(meals[i])
+	}
+}
+
+// Classifies the diet of a meal by the keywords of its name and its mandatory supplements
+// Optional supplements are left out, because they are ordered separately
+// Explicit labels like 'vegetarisch' win over meat keywords, vegan meals must not list milk or eggs as allergens
+func (lexicon DietLexicon) Classify(meal Meal) []DietTag {
+	name := normalizeWords(meal.Name)
+	supplements := make([]string, 0, len(meal.MandatorySupplements))
+	for _, supplement := range meal.MandatorySupplements {
+		supplements = append(supplements, normalizeWords(supplement.Name))
+	}
+	supplementText := strings.Join(supplements, " ")
+
+	// the confidence of the evidence for a keyword group, zero if neither the name nor a supplement matches
+	evidence := func(group KeywordGroup) float64 {
+		if group.matches(name) {
+			return confidenceName
+		}
+		if group.matches(supplementText) {
+			return confidenceSupplement
+		}
+		return 0
+	}
+
+	var tags []DietTag
+	veganLabel := lexicon.Vegan.matches(name)
+	vegetarianLabel := veganLabel || lexicon.Vegetarian.matches(name)
+	if vegetarianLabel {
+		tags = append(tags, DietTag{Tag: DietVegetarian, Confidence: confidenceLabel})
+		if veganLabel {
+			tags = append(tags, DietTag{Tag: DietVegan, Confidence: confidenceLabel})
+		}
+		return tags
+	}
+
+	pork, fish := evidence(lexicon.Pork), evidence(lexicon.Fish)
+	if pork > 0 {
+		tags = append(tags, DietTag{Tag: DietContainsPork, Confidence: pork})
+	}
+	if fish > 0 {
+		tags = append(tags, DietTag{Tag: DietContainsFish, Confidence: fish})
+	}
+	if pork > 0 || fish > 0 || evidence(lexicon.Meat) > 0 {
+		return tags
+	}
+
+	tags = append(tags, DietTag{Tag: DietVegetarian, Confidence: confidenceAbsence})
+	if evidence(lexicon.AnimalProducts) == 0 && !containsAny(meal.Allergens, AllergenMilk, AllergenEggs) {
+		tags = append(tags, DietTag{Tag: DietVegan, Confidence: confidenceVegan})
+	}
+	return tags
+}
+
+// Tells if a normalized text contains one of the keywords of the group
+func (group KeywordGroup) matches(text string) bool {
+	for _, keyword := range group.Contains {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+
+	paddedText := " " + text + " "
+	for _, word := range group.Words {
+		if strings.Contains(paddedText, " "+normalizeWords(word)+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Lower cases a text and separates its words by single spaces, so whole words can be matched
+func normalizeWords(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Tells if the values contain one of the candidates
+func containsAny(values []string, candidates ...string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Builds the id of the diet override of a meal, which is the same for all meals with the same normalized name
+func DietOverrideId(mealName string) string {
+	return toSha1(NormalizeMealName(mealName))
+}
+
+// Replaces the classified diet tags of all meals that have an override with the overridden tags
+func ApplyDietOverrides(meals []Meal, overrides []DietOverride) {
+	overridesById := make(map[string]DietOverride)
+	for _, override := range overrides {
+		overridesById[override.Id] = override
+	}
+
+	for i := range meals {
+		override, found := overridesById[DietOverrideId(meals[i].Name)]
+		if !found {
+			continue
+		}
+
+		meals[i].DietTags = make([]DietTag, 0, len(override.Tags))
+		for _, tag := range override.Tags {
+			meals[i].DietTags = append(meals[i].DietTags, DietTag{Tag: tag, Confidence: confidenceOverride, Overridden: true})
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package webcrawler
+
+import (
+	"testing"
+)
+
+func TestDietClassification(t *testing.T) {
+	lexicon, err := LoadDietLexicon("../lexicons/diet.yaml")
+	if err != nil {
+		t.Fatalf("expected the lexicon to load but got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		meal Meal
+		want []DietTag
+	}{
+		{
+			name: "expect pork from the name",
+			meal: Meal{Name: "Schweinebraten mit Knödel"},
+			want: []DietTag{{Tag: DietContainsPork, Confidence: confidenceName}},
+		},
+		{
+			name: "expect fish from the name",
+			meal: Meal{Name: "Gebratenes Lachsfilet"},
+			want: []DietTag{{Tag: DietContainsFish, Confidence: confidenceName}},
+		},
+		{
+			name: "expect a lower confidence for keywords of supplements",
+			meal: Meal{Name: "Spaghetti", MandatorySupplements: []Supplement{{Name: "Speckwürfel"}}},
+			want: []DietTag{{Tag: DietContainsPork, Confidence: confidenceSupplement}},
+		},
+		{
+			name: "expect meat without pork or fish to have no tags",
+			meal: Meal{Name: "Rindergulasch"},
+			want: nil,
+		},
+		{
+			name: "expect meat dishes that are not named after their animal not to be vegetarian",
+			meal: Meal{Name: "Schnitzel mit Pommes"},
+			want: nil,
+		},
+		{
+			name: "expect maultaschen not to be vegetarian",
+			meal: Meal{Name: "Maultaschen in Brühe"},
+			want: nil,
+		},
+		{
+			name: "expect a kotelett not to be vegetarian",
+			meal: Meal{Name: "Kotelett mit Bratkartoffeln"},
+			want: nil,
+		},
+		{
+			name: "expect the vegetarian label to win over meat keywords",
+			meal: Meal{Name: "Vegetarische Bratwurst"},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceLabel}},
+		},
+		{
+			name: "expect the vegan label to imply vegetarian",
+			meal: Meal{Name: "Veganes Curry"},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceLabel}, {Tag: DietVegan, Confidence: confidenceLabel}},
+		},
+		{
+			name: "expect vegetarian and vegan without any keyword",
+			meal: Meal{Name: "Linsen mit Spätzle"},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}, {Tag: DietVegan, Confidence: confidenceVegan}},
+		},
+		{
+			name: "expect no vegan tag if milk is an allergen",
+			meal: Meal{Name: "Linsen mit Spätzle", Allergens: []string{AllergenMilk}},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}},
+		},
+		{
+			name: "expect no vegan tag with animal products",
+			meal: Meal{Name: "Käsespätzle"},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}},
+		},
+		{
+			name: "expect words to match whole words only",
+			meal: Meal{Name: "Champignons in Rahm", Allergens: []string{AllergenMilk}},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}},
+		},
+		{
+			name: "expect words to match in any case and punctuation",
+			meal: Meal{Name: "Toast mit HAM, Käse"},
+			want: []DietTag{{Tag: DietContainsPork, Confidence: confidenceName}},
+		},
+		{
+			name: "expect optional supplements to be ignored",
+			meal: Meal{Name: "Ofenkartoffel", OptionalSupplements: []Supplement{{Name: "Thunfisch"}}, Allergens: []string{AllergenMilk}},
+			want: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := lexicon.Classify(test.meal)
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %v but got %v", test.want, got)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("expected %v but got %v", test.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDietOverrides(t *testing.T) {
+	meals := []Meal{
+		{Name: "Gemüsecurry", DietTags: []DietTag{{Tag: DietVegetarian, Confidence: confidenceAbsence}}},
+		{Name: "Schnitzel", DietTags: nil},
+	}
+	overrides := []DietOverride{{Id: DietOverrideId("  gemüsecurry "), MealName: "Gemüsecurry", Tags: []string{DietContainsFish}}}
+
+	ApplyDietOverrides(meals, overrides)
+
+	t.Run("expect the override to replace the classified tags", func(t *testing.T) {
+		want := DietTag{Tag: DietContainsFish, Confidence: confidenceOverride, Overridden: true}
+		if len(meals[0].DietTags) != 1 || meals[0].DietTags[0] != want {
+			t.Fatalf("expected %v but got %v", want, meals[0].DietTags)
+		}
+	})
+
+	t.Run("expect meals without override to keep their tags", func(t *testing.T) {
+		if meals[1].DietTags != nil {
+			t.Fatalf("expected no tags but got %v", meals[1].DietTags)
+		}
+	})
+
+	t.Run("expect only canonical tags to be valid", func(t *testing.T) {
+		if !IsDietTag(DietVegan) || IsDietTag("vegan") || IsDietTag("GLUTEN_FREE") {
+			t.Fatalf("expected only the canonical tags to be valid")
+		}
+	})
+}
@@ -0,0 +1,508 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/avast/retry-go"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Fetches web pages over http
+// Requests time out, are retried with a backoff on server and network errors and identify themselves with a user agent
+// The fetcher is polite: it honours the robots.txt of every host, limits the requests per host and caches responses
+// Hosts that require a login get the credentials of their authenticator
+type Fetcher struct {
+	client        *http.Client
+	transport     *http.Transport
+	userAgent     string
+	retryAttempts uint
+	retryDelay    time.Duration
+	retryMaxDelay time.Duration
+	respectRobots bool
+	limiter       *hostLimiter
+	cache         *responseCache
+
+	robotsMutex sync.Mutex
+	robots      map[string]robotsEntry // the robots.txt rules by host
+
+	validatorMutex sync.Mutex
+	validators     map[string]PageValidators // the validators of every fetched page by url
+	validatorStore ValidatorStore            // keeps the validators across restarts, nil to keep them in memory only
+
+	authMutex      sync.Mutex
+	authenticators map[string]Authenticator // the authenticators by host
+}
+
+// Identifies the version of a fetched page, so it is only sent again if it was modified
+type PageValidators struct {
+	ETag         string `json:"etag,omitempty"`         // the entity tag of the page
+	LastModified string `json:"lastModified,omitempty"` // the time the page was modified last
+}
+
+// Keeps the validators of fetched pages beyond the lifetime of the process,
+// so a recurring crawl after a restart still requests its pages conditionally
+// Implementations must be safe for concurrent use
+type ValidatorStore interface {
+	// Returns the validators of the page, false if none are kept
+	Load(ctx context.Context, pageUrl string) (PageValidators, bool)
+
+	// Keeps the validators of the page, replacing any former ones
+	Save(ctx context.Context, pageUrl string, validators PageValidators)
+
+	// Drops the validators of the page
+	Forget(ctx context.Context, pageUrl string)
+}
+
+// Is returned for a page that was not modified since it was fetched last
+var ErrNotModified = errors.New("the page was not modified since it was fetched last")
+
+// The robots.txt rules of a host and when they have to be requested again
+type robotsEntry struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+// How long the robots.txt of a host is kept before it is requested again
+const robotsTtl = 24 * time.Hour
+
+// Represents a response whose status code lies outside of the 2xx range
+type HttpStatusError struct {
+	Url        string
+	StatusCode int
+	Status     string
+}
+
+func (err *HttpStatusError) Error() string {
+	return fmt.Sprintf("requesting %s failed with status %s", err.Url, err.Status)
+}
+
+// Tells if a request that failed with this status might succeed when it is retried
+func (err *HttpStatusError) Temporary() bool {
+	return err.StatusCode >= http.StatusInternalServerError
+}
+
+// The fetcher that is used for all pages requested by menu sources
+var defaultFetcher *Fetcher
+
+func init() {
+	fetcher, err := NewFetcher(config.Get())
+	if err != nil {
+		log.Fatal("Failed to create the http fetcher ", err)
+	}
+	defaultFetcher = fetcher
+}
+
+// Creates a new fetcher from the http settings of the specified configuration
+// Returns an error if the configured proxy is not a valid url or the auth settings are incomplete
+func NewFetcher(cfg config.Config) (*Fetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.HttpProxyUrl != "" {
+		proxyUrl, err := url.Parse(cfg.HttpProxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %s: %w", cfg.HttpProxyUrl, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	// every request is sent at least once
+	retryAttempts := uint(cfg.HttpRetryAttempts)
+	if retryAttempts == 0 {
+		retryAttempts = 1
+	}
+
+	// the cookie jar keeps the sessions of form logins
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher := &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(cfg.HttpTimeoutInSeconds) * time.Second,
+			Jar:       jar,
+		},
+		transport:      transport,
+		userAgent:      cfg.HttpUserAgent,
+		retryAttempts:  retryAttempts,
+		retryDelay:     time.Duration(cfg.HttpRetryDelayInMilliseconds) * time.Millisecond,
+		retryMaxDelay:  time.Duration(cfg.HttpRetryMaxDelayInMilliseconds) * time.Millisecond,
+		respectRobots:  cfg.HttpRespectRobots,
+		limiter:        newHostLimiter(cfg.HttpRequestsPerSecond, cfg.HttpBurst),
+		cache:          newResponseCache(time.Duration(cfg.HttpCacheTtlInSeconds) * time.Second),
+		robots:         make(map[string]robotsEntry),
+		validators:     make(map[string]PageValidators),
+		authenticators: make(map[string]Authenticator),
+	}
+
+	authenticator, err := newConfiguredAuthenticator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http auth settings: %w", err)
+	}
+	if authenticator != nil {
+		hosts := authHosts(cfg)
+		if len(hosts) == 0 {
+			return nil, fmt.Errorf("no hosts to send the http auth credentials to")
+		}
+		for _, host := range hosts {
+			if err := fetcher.UseAuthenticator(host, authenticator); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fetcher, nil
+}
+
+// Authenticates all requests to the host, which is the host name with an optional port, e.g. bistro.cgm.ag
+// Returns an error if the authenticator cannot configure the transport of the fetcher
+func (fetcher *Fetcher) UseAuthenticator(host string, authenticator Authenticator) error {
+	if configurer, ok := authenticator.(TransportConfigurer); ok {
+		if err := configurer.ConfigureTransport(fetcher.transport); err != nil {
+			return err
+		}
+	}
+
+	fetcher.authMutex.Lock()
+	defer fetcher.authMutex.Unlock()
+	fetcher.authenticators[host] = authenticator
+	return nil
+}
+
+// Requests the specified url unless its response is still cached
+// A page that was fetched before is only sent again if the server says it was modified
+// Server and network errors are retried with an exponential backoff until the context is done
+// Returns the response body, ErrNotModified if the page was not modified, a RobotsError if the host disallows the url
+// or a HttpStatusError if the final response is no success
+func (fetcher *Fetcher) Fetch(ctx context.Context, pageUrl string) ([]byte, error) {
+	return fetcher.fetch(ctx, pageUrl, true)
+}
+
+// Requests the specified url like Fetch, but never conditionally
+// Use it for pages whose content is needed even if it was not modified, e.g. detail pages
+func (fetcher *Fetcher) FetchUnconditional(ctx context.Context, pageUrl string) ([]byte, error) {
+	return fetcher.fetch(ctx, pageUrl, false)
+}
+
+// Requests the specified url, conditionally if its validators are kept
+func (fetcher *Fetcher) fetch(ctx context.Context, pageUrl string, conditional bool) ([]byte, error) {
+	if body, found := fetcher.cache.get(pageUrl, time.Now()); found {
+		return body, nil
+	}
+
+	parsedUrl, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var crawlDelay time.Duration
+	if fetcher.respectRobots {
+		rules, err := fetcher.robotsRules(ctx, parsedUrl)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(parsedUrl.RequestURI()) {
+			return nil, &RobotsError{Url: pageUrl}
+		}
+		crawlDelay = rules.crawlDelay
+	}
+
+	var validators PageValidators
+	if conditional {
+		validators = fetcher.loadValidators(ctx, pageUrl)
+	}
+
+	body, validators, err := fetcher.fetchWithRetries(ctx, pageUrl, parsedUrl.Host, crawlDelay, validators)
+	if err != nil {
+		return nil, err
+	}
+
+	if conditional {
+		fetcher.saveValidators(ctx, pageUrl, validators)
+		trackFetchedPage(ctx, pageUrl)
+	}
+
+	fetcher.cache.put(pageUrl, body, time.Now())
+	return body, nil
+}
+
+// Returns the kept validators of the page
+// Pages that were not fetched by this process yet are looked up in the validator store
+func (fetcher *Fetcher) loadValidators(ctx context.Context, pageUrl string) PageValidators {
+	fetcher.validatorMutex.Lock()
+	validators, found := fetcher.validators[pageUrl]
+	store := fetcher.validatorStore
+	fetcher.validatorMutex.Unlock()
+	if found || store == nil {
+		return validators
+	}
+
+	validators, _ = store.Load(ctx, pageUrl)
+	return validators
+}
+
+// Keeps the validators of a fetched page, a page without validators is requested unconditionally next time
+func (fetcher *Fetcher) saveValidators(ctx context.Context, pageUrl string, validators PageValidators) {
+	fetcher.validatorMutex.Lock()
+	if validators.ETag != "" || validators.LastModified != "" {
+		fetcher.validators[pageUrl] = validators
+	} else {
+		delete(fetcher.validators, pageUrl)
+	}
+	store := fetcher.validatorStore
+	fetcher.validatorMutex.Unlock()
+	if store == nil {
+		return
+	}
+
+	if validators.ETag != "" || validators.LastModified != "" {
+		store.Save(ctx, pageUrl, validators)
+	} else {
+		store.Forget(ctx, pageUrl)
+	}
+}
+
+// Returns the robots.txt rules of the host of the url, which are requested once a day
+// Hosts without a robots.txt allow everything
+func (fetcher *Fetcher) robotsRules(ctx context.Context, pageUrl *url.URL) (robotsRules, error) {
+	fetcher.robotsMutex.Lock()
+	entry, found := fetcher.robots[pageUrl.Host]
+	fetcher.robotsMutex.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.rules, nil
+	}
+
+	robotsUrl := pageUrl.Scheme + "://" + pageUrl.Host + "/robots.txt"
+	body, _, err := fetcher.fetchWithRetries(ctx, robotsUrl, pageUrl.Host, 0, PageValidators{})
+
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) && !statusErr.Temporary() {
+		entry.rules = robotsRules{}
+	} else if err != nil {
+		return robotsRules{}, fmt.Errorf("requesting the robots.txt of %s failed: %w", pageUrl.Host, err)
+	} else {
+		entry.rules = parseRobots(body, fetcher.userAgent)
+	}
+
+	entry.expires = time.Now().Add(robotsTtl)
+	fetcher.robotsMutex.Lock()
+	fetcher.robots[pageUrl.Host] = entry
+	fetcher.robotsMutex.Unlock()
+
+	return entry.rules, nil
+}
+
+// Requests the specified url as soon as the limiter of its host allows
+// Server and network errors are retried with an exponential backoff until the context is done
+func (fetcher *Fetcher) fetchWithRetries(ctx context.Context, pageUrl string, host string, crawlDelay time.Duration, validators PageValidators) (body []byte, newValidators PageValidators, err error) {
+	err = retry.Do(
+		func() error {
+			if err := fetcher.limiter.wait(ctx, host, crawlDelay); err != nil {
+				return err
+			}
+			body, newValidators, err = fetcher.fetchOnce(ctx, pageUrl, validators)
+			return err
+		},
+		retry.Attempts(fetcher.retryAttempts),
+		retry.Delay(fetcher.retryDelay),
+		retry.MaxDelay(fetcher.retryMaxDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(func(err error) bool {
+			return ctx.Err() == nil && isRetryable(err)
+		}),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("#%d request to %s failed: %s", n, pageUrl, err)
+		}),
+	)
+
+	return body, newValidators, err
+}
+
+// Requests the specified url exactly once
+// The request is conditional if validators of a former response are passed
+// Returns the response body with its validators, ErrNotModified if the server answered a conditional request
+// with 304 or a HttpStatusError if the response is no success
+func (fetcher *Fetcher) fetchOnce(ctx context.Context, pageUrl string, validators PageValidators) ([]byte, PageValidators, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageUrl, nil)
+	if err != nil {
+		return nil, validators, err
+	}
+	if fetcher.userAgent != "" {
+		request.Header.Set("User-Agent", fetcher.userAgent)
+	}
+	if validators.ETag != "" {
+		request.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		request.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	response, err := fetcher.send(ctx, request)
+	if err != nil {
+		return nil, validators, err
+	}
+	defer response.Body.Close()
+
+	conditional := validators.ETag != "" || validators.LastModified != ""
+	if response.StatusCode == http.StatusNotModified && conditional {
+		return nil, validators, ErrNotModified
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, validators, &HttpStatusError{
+			Url:        pageUrl,
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+		}
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	return body, PageValidators{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}, err
+}
+
+// Sends the request with the credentials of the authenticator of its host
+// A request whose session has expired is sent once more after a new login
+func (fetcher *Fetcher) send(ctx context.Context, request *http.Request) (*http.Response, error) {
+	fetcher.authMutex.Lock()
+	authenticator := fetcher.authenticators[request.URL.Host]
+	fetcher.authMutex.Unlock()
+
+	if authenticator == nil {
+		return fetcher.client.Do(request)
+	}
+	if err := authenticator.Authenticate(ctx, fetcher.client, request); err != nil {
+		return nil, fmt.Errorf("authenticating at %s failed: %w", request.URL.Host, err)
+	}
+
+	response, err := fetcher.client.Do(request)
+	session, ok := authenticator.(SessionAuthenticator)
+	if err != nil || !ok || !session.SessionExpired(response) {
+		return response, err
+	}
+
+	response.Body.Close()
+	session.Invalidate()
+
+	// the client added the expired cookies to the request, the jar adds the renewed ones to a clone
+	renewedRequest := request.Clone(ctx)
+	renewedRequest.Header.Del("Cookie")
+	if err := session.Authenticate(ctx, fetcher.client, renewedRequest); err != nil {
+		return nil, fmt.Errorf("renewing the session at %s failed: %w", request.URL.Host, err)
+	}
+	return fetcher.client.Do(renewedRequest)
+}
+
+// Keeps the validators of all fetched pages in the store, so they survive a restart
+// Validators the store holds already are used for pages this process did not fetch yet
+func (fetcher *Fetcher) UseValidatorStore(store ValidatorStore) {
+	fetcher.validatorMutex.Lock()
+	defer fetcher.validatorMutex.Unlock()
+	fetcher.validatorStore = store
+}
+
+// Forgets the validators of all pages this process fetched, so every page is sent again on its next fetch
+func (fetcher *Fetcher) forgetValidators() {
+	fetcher.validatorMutex.Lock()
+	pageUrls := make([]string, 0, len(fetcher.validators))
+	for pageUrl := range fetcher.validators {
+		pageUrls = append(pageUrls, pageUrl)
+	}
+	fetcher.validatorMutex.Unlock()
+
+	fetcher.forgetPageValidators(context.Background(), pageUrls)
+}
+
+// Forgets the validators of the pages, so they are sent again on their next fetch
+func (fetcher *Fetcher) forgetPageValidators(ctx context.Context, pageUrls []string) {
+	fetcher.validatorMutex.Lock()
+	for _, pageUrl := range pageUrls {
+		delete(fetcher.validators, pageUrl)
+	}
+	store := fetcher.validatorStore
+	fetcher.validatorMutex.Unlock()
+	if store == nil {
+		return
+	}
+
+	for _, pageUrl := range pageUrls {
+		store.Forget(ctx, pageUrl)
+	}
+}
+
+// Keeps the validators of all pages fetched by menu sources in the store
+func UsePageValidatorStore(store ValidatorStore) {
+	defaultFetcher.UseValidatorStore(store)
+}
+
+// Forgets the validators of all pages fetched by menu sources
+func ForgetPageValidators() {
+	defaultFetcher.forgetValidators()
+}
+
+// Forgets the validators of the pages fetched with the context, which must be tracking its fetched pages
+// Crawls whose result was not stored completely call this, so their pages are parsed again next time,
+// while the pages of other crawls that run at the same time keep their validators
+// The validators are forgotten even if the context is done already, e.g. because the crawl was interrupted
+func ForgetFetchedPageValidators(ctx context.Context) {
+	defaultFetcher.forgetPageValidators(context.Background(), FetchedPages(ctx))
+}
+
+// The key of the fetched pages of a context
+type fetchedPagesKey struct{}
+
+// The urls of the pages fetched with a context
+type fetchedPages struct {
+	mutex    sync.Mutex
+	pageUrls []string
+}
+
+// Derives a context that records the urls of all pages that are fetched conditionally with it
+func TrackFetchedPages(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fetchedPagesKey{}, &fetchedPages{})
+}
+
+// Returns the urls of the pages fetched conditionally with the context, none if the context does not track them
+func FetchedPages(ctx context.Context) []string {
+	pages, ok := ctx.Value(fetchedPagesKey{}).(*fetchedPages)
+	if !ok {
+		return nil
+	}
+
+	pages.mutex.Lock()
+	defer pages.mutex.Unlock()
+	return append([]string(nil), pages.pageUrls...)
+}
+
+// Records a fetched page if the context tracks its fetched pages
+func trackFetchedPage(ctx context.Context, pageUrl string) {
+	if pages, ok := ctx.Value(fetchedPagesKey{}).(*fetchedPages); ok {
+		pages.mutex.Lock()
+		pages.pageUrls = append(pages.pageUrls, pageUrl)
+		pages.mutex.Unlock()
+	}
+}
+
+// Tells if a failed request should be retried
+// Only network errors and server errors are worth a retry
+func isRetryable(err error) bool {
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
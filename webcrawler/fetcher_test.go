@@ -0,0 +1,324 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetcher(t *testing.T) {
+	fetcher, _ := NewFetcher(config.Get())
+
+	t.Run("expect server errors to be retried", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		body, err := fetcher.Fetch(context.Background(), server.URL)
+		if err != nil || string(body) != "menu" || requests != 3 {
+			t.Fatalf("expected the body after 3 requests but got %q after %d requests: %v", body, requests, err)
+		}
+	})
+
+	t.Run("expect client errors to be returned as typed errors without retry", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		_, err := fetcher.Fetch(context.Background(), server.URL)
+		var statusErr *HttpStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected a status error with the code 404 but got %v", err)
+		}
+		if requests != 1 {
+			t.Fatalf("expected a single request but got %d", requests)
+		}
+	})
+
+	t.Run("expect persisting server errors to be returned after all attempts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		_, err := fetcher.Fetch(context.Background(), server.URL)
+		var statusErr *HttpStatusError
+		if !errors.As(err, &statusErr) || !statusErr.Temporary() {
+			t.Fatalf("expected a temporary status error but got %v", err)
+		}
+	})
+
+	t.Run("expect the configured user agent", func(t *testing.T) {
+		var userAgent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userAgent = r.UserAgent()
+		}))
+		defer server.Close()
+
+		fetcher.Fetch(context.Background(), server.URL)
+		if userAgent != config.Get().HttpUserAgent {
+			t.Fatalf("expected the user agent %s but got %s", config.Get().HttpUserAgent, userAgent)
+		}
+	})
+
+	t.Run("expect a cancelled context to stop the retries", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := fetcher.Fetch(ctx, server.URL); !errors.Is(err, context.Canceled) || requests != 0 {
+			t.Fatalf("expected a cancellation error without requests but got %v after %d requests", err, requests)
+		}
+	})
+
+	t.Run("expect a hung server to be abandoned at the deadline", func(t *testing.T) {
+		hung := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-hung
+		}))
+		defer server.Close()
+		defer close(hung)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := fetcher.Fetch(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a deadline error but got %v", err)
+		}
+	})
+
+	t.Run("expect an error for an invalid proxy url", func(t *testing.T) {
+		cfg := config.Get()
+		cfg.HttpProxyUrl = "http://proxy:port"
+		if _, err := NewFetcher(cfg); err == nil {
+			t.Fatalf("expected an error for an invalid proxy url")
+		}
+	})
+}
+
+func TestPoliteFetcher(t *testing.T) {
+	cfg := config.Get()
+	cfg.HttpRespectRobots = true
+	cfg.HttpCacheTtlInSeconds = 60
+
+	robotsRequests, pageRequests := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests++
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		pageRequests++
+		w.Write([]byte("menu"))
+	}))
+	defer server.Close()
+
+	fetcher, _ := NewFetcher(cfg)
+
+	t.Run("expect a disallowed url not to be requested", func(t *testing.T) {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/private/menu")
+		var robotsErr *RobotsError
+		if !errors.As(err, &robotsErr) || pageRequests != 0 {
+			t.Fatalf("expected a robots error without requests but got %v after %d requests", err, pageRequests)
+		}
+	})
+
+	t.Run("expect repeated requests to be served from the cache", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if body, err := fetcher.Fetch(context.Background(), server.URL+"/index.php?day=8"); err != nil || string(body) != "menu" {
+				t.Fatalf("expected the menu but got %q: %v", body, err)
+			}
+		}
+		if pageRequests != 1 {
+			t.Fatalf("expected a single request but got %d", pageRequests)
+		}
+	})
+
+	t.Run("expect the robots.txt to be requested once", func(t *testing.T) {
+		if robotsRequests != 1 {
+			t.Fatalf("expected a single robots.txt request but got %d", robotsRequests)
+		}
+	})
+
+	t.Run("expect everything to be allowed without a robots.txt", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		if _, err := fetcher.Fetch(context.Background(), server.URL+"/private"); err != nil {
+			t.Fatalf("expected the page to be allowed but got %v", err)
+		}
+	})
+
+	t.Run("expect the rate to be limited per host", func(t *testing.T) {
+		cfg.HttpRespectRobots = false
+		cfg.HttpCacheTtlInSeconds = 0
+		cfg.HttpRequestsPerSecond = 20
+		limitedFetcher, _ := NewFetcher(cfg)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			limitedFetcher.Fetch(context.Background(), server.URL)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Fatalf("expected 3 requests to take at least 100ms but took %s", elapsed)
+		}
+	})
+}
+
+func TestConditionalFetching(t *testing.T) {
+	fetcher, _ := NewFetcher(config.Get())
+
+	t.Run("expect a page with an unchanged etag not to be sent again", func(t *testing.T) {
+		var ifNoneMatch string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifNoneMatch = r.Header.Get("If-None-Match")
+			w.Header().Set("ETag", `"week-24"`)
+			if ifNoneMatch == `"week-24"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		if body, err := fetcher.Fetch(context.Background(), server.URL); err != nil || string(body) != "menu" {
+			t.Fatalf("expected the menu on the first fetch but got %q: %v", body, err)
+		}
+		if _, err := fetcher.Fetch(context.Background(), server.URL); !errors.Is(err, ErrNotModified) {
+			t.Fatalf("expected the page not to be modified but got %v with If-None-Match %s", err, ifNoneMatch)
+		}
+	})
+
+	t.Run("expect the last modified time to be sent again", func(t *testing.T) {
+		lastModified := "Mon, 08 Jun 2020 10:00:00 GMT"
+		var ifModifiedSince string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifModifiedSince = r.Header.Get("If-Modified-Since")
+			w.Header().Set("Last-Modified", lastModified)
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		fetcher.Fetch(context.Background(), server.URL)
+		if body, err := fetcher.Fetch(context.Background(), server.URL); err != nil || string(body) != "menu" {
+			t.Fatalf("expected a modified page to be sent again but got %q: %v", body, err)
+		}
+		if ifModifiedSince != lastModified {
+			t.Fatalf("expected If-Modified-Since %s but got %s", lastModified, ifModifiedSince)
+		}
+	})
+
+	t.Run("expect forgotten validators to request the page unconditionally", func(t *testing.T) {
+		var ifNoneMatch string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ifNoneMatch = r.Header.Get("If-None-Match")
+			w.Header().Set("ETag", `"week-24"`)
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		fetcher.Fetch(context.Background(), server.URL)
+		fetcher.forgetValidators()
+		fetcher.Fetch(context.Background(), server.URL)
+		if ifNoneMatch != "" {
+			t.Fatalf("expected an unconditional request but got If-None-Match %s", ifNoneMatch)
+		}
+	})
+
+	t.Run("expect only the validators of the forgotten crawl to be forgotten", func(t *testing.T) {
+		conditional := make(map[string]bool)
+		var mutex sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			conditional[r.URL.Path] = r.Header.Get("If-None-Match") != ""
+			mutex.Unlock()
+			w.Header().Set("ETag", `"week-24"`)
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		failedCrawl := TrackFetchedPages(context.Background())
+		otherCrawl := TrackFetchedPages(context.Background())
+		fetcher.Fetch(failedCrawl, server.URL+"/failed")
+		fetcher.Fetch(otherCrawl, server.URL+"/other")
+		if pages := FetchedPages(failedCrawl); len(pages) != 1 || pages[0] != server.URL+"/failed" {
+			t.Fatalf("expected the page of the failed crawl to be tracked but got %v", pages)
+		}
+
+		fetcher.forgetPageValidators(context.Background(), FetchedPages(failedCrawl))
+		fetcher.Fetch(context.Background(), server.URL+"/failed")
+		fetcher.Fetch(context.Background(), server.URL+"/other")
+		if conditional["/failed"] || !conditional["/other"] {
+			t.Fatalf("expected only the page of the other crawl to be requested conditionally but got %v", conditional)
+		}
+	})
+
+	t.Run("expect stored validators to survive a restart", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"week-24"`)
+			if r.Header.Get("If-None-Match") == `"week-24"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("menu"))
+		}))
+		defer server.Close()
+
+		store := &memoryValidatorStore{validators: make(map[string]PageValidators)}
+		fetcher.UseValidatorStore(store)
+		fetcher.Fetch(context.Background(), server.URL)
+
+		restarted, _ := NewFetcher(config.Get())
+		restarted.UseValidatorStore(store)
+		if _, err := restarted.Fetch(context.Background(), server.URL); !errors.Is(err, ErrNotModified) {
+			t.Fatalf("expected the stored etag to be sent after a restart but got %v", err)
+		}
+	})
+}
+
+// Keeps validators in a map, as a stand-in for a store that survives a restart
+type memoryValidatorStore struct {
+	mutex      sync.Mutex
+	validators map[string]PageValidators
+}
+
+func (store *memoryValidatorStore) Load(ctx context.Context, pageUrl string) (PageValidators, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	validators, found := store.validators[pageUrl]
+	return validators, found
+}
+
+func (store *memoryValidatorStore) Save(ctx context.Context, pageUrl string, validators PageValidators) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.validators[pageUrl] = validators
+}
+
+func (store *memoryValidatorStore) Forget(ctx context.Context, pageUrl string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.validators, pageUrl)
+}
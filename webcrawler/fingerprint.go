@@ -0,0 +1,167 @@
+package webcrawler
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+)
+
+// Represents the structural skeleton of a fetched page around its date, day and meal nodes
+// The skeleton ignores texts and inline styles, so it only changes if the page layout changes
+type PageFingerprint struct {
+	Source         string   `json:"source"`         // the menu source the page was fetched from
+	ProfileVersion int      `json:"profileVersion"` // the version of the selector profile that selected the nodes
+	Hash           string   `json:"hash"`           // sha1 of all shapes
+	Shapes         []string `json:"shapes"`         // the distinct dom path shapes, ordered
+}
+
+// Represents the fingerprint a source is expected to have
+type LayoutBaseline struct {
+	Id          string          `json:"_key,omitempty"` // the source and profile version, e.g. cgm-v1
+	Fingerprint PageFingerprint `json:"fingerprint"`    // the fingerprint of the first page that was parsed successfully
+	CreatedTime string          `json:"createdTime"`    // the time the baseline was recorded
+}
+
+func (baseline LayoutBaseline) GetId() string {
+	return baseline.Id
+}
+
+// Represents a fetched page whose layout deviates from the baseline of its source
+type LayoutDrift struct {
+	Id             string   `json:"_key,omitempty"`          // unique identifier for the database
+	JobId          string   `json:"jobId,omitempty"`         // the job whose crawl fetched the page
+	DetectedTime   string   `json:"detectedTime,omitempty"`  // the time the drift was detected
+	Source         string   `json:"source"`                  // the menu source of the page
+	ProfileVersion int      `json:"profileVersion"`          // the version of the selector profile
+	Similarity     float64  `json:"similarity"`              // the share of shapes the page and the baseline have in common
+	BaselineHash   string   `json:"baselineHash"`            // the fingerprint hash of the baseline
+	Hash           string   `json:"hash"`                    // the fingerprint hash of the page
+	MissingShapes  []string `json:"missingShapes,omitempty"` // shapes of the baseline the page does not have
+	NewShapes      []string `json:"newShapes,omitempty"`     // shapes of the page the baseline does not have
+}
+
+func (drift LayoutDrift) GetId() string {
+	return drift.Id
+}
+
+// Returns the id of the baseline the fingerprint is compared with
+// Every profile version has a baseline of its own, because a new profile is made for a new layout
+func (fingerprint PageFingerprint) BaselineId() string {
+	return fmt.Sprintf("%s-v%d", fingerprint.Source, fingerprint.ProfileVersion)
+}
+
+// Fingerprints the structural skeleton of a page around the nodes the profile selects
+// Day and date nodes are described by their path from the document root, meals by their path within the day
+// and the content of meals by the paths of all their descendants
+func fingerprintPage(doc *goquery.Document, profile *SelectorProfile) PageFingerprint {
+	shapes := make(map[string]bool)
+
+	doc.Find(profile.Selectors.Date).Each(func(i int, dateSelection *goquery.Selection) {
+		shapes["date "+nodePath(dateSelection.Nodes[0], nil)] = true
+	})
+
+	doc.Find(profile.Selectors.Day).Each(func(i int, daySelection *goquery.Selection) {
+		day := daySelection.Nodes[0]
+		shapes["day "+nodePath(day, nil)] = true
+
+		daySelection.Find(profile.Selectors.Meal).Each(func(j int, mealSelection *goquery.Selection) {
+			meal := mealSelection.Nodes[0]
+			shapes["meal "+nodePath(meal, day)] = true
+
+			mealSelection.Find("*").Each(func(k int, contentSelection *goquery.Selection) {
+				shapes["meal content "+nodePath(contentSelection.Nodes[0], meal)] = true
+			})
+		})
+	})
+
+	fingerprint := PageFingerprint{
+		Source:         profile.Source,
+		ProfileVersion: profile.Version,
+		Shapes:         make([]string, 0, len(shapes)),
+	}
+	for shape := range shapes {
+		fingerprint.Shapes = append(fingerprint.Shapes, shape)
+	}
+	sort.Strings(fingerprint.Shapes)
+	fingerprint.Hash = toSha1(strings.Join(fingerprint.Shapes, "\n"))
+
+	return fingerprint
+}
+
+// Describes the path of element shapes from an ancestor down to a node, e.g. div.table>div#day
+// Leave the ancestor nil to describe the path from the document root
+func nodePath(node *html.Node, ancestor *html.Node) string {
+	var path []string
+	for current := node; current != nil && current != ancestor; current = current.Parent {
+		if current.Type == html.ElementNode {
+			path = append([]string{nodeShape(current)}, path...)
+		}
+	}
+	return strings.Join(path, ">")
+}
+
+// Describes an element by its tag, id and classes, e.g. div#day.table-col
+func nodeShape(node *html.Node) string {
+	shape := node.Data
+	var classes []string
+	for _, attribute := range node.Attr {
+		switch attribute.Key {
+		case "id":
+			shape += "#" + attribute.Val
+		case "class":
+			classes = strings.Fields(attribute.Val)
+		}
+	}
+
+	sort.Strings(classes)
+	for _, class := range classes {
+		shape += "." + class
+	}
+	return shape
+}
+
+// Compares the fingerprint of a page with the baseline of its source
+// Returns nil if the share of common shapes reaches the threshold, otherwise the drift with all deviating shapes
+func DetectDrift(baseline PageFingerprint, current PageFingerprint, threshold float64) *LayoutDrift {
+	if baseline.Hash == current.Hash {
+		return nil
+	}
+
+	baselineShapes := make(map[string]bool)
+	for _, shape := range baseline.Shapes {
+		baselineShapes[shape] = true
+	}
+
+	drift := LayoutDrift{
+		Source:         current.Source,
+		ProfileVersion: current.ProfileVersion,
+		BaselineHash:   baseline.Hash,
+		Hash:           current.Hash,
+	}
+
+	common := 0
+	for _, shape := range current.Shapes {
+		if baselineShapes[shape] {
+			common++
+			delete(baselineShapes, shape)
+		} else {
+			drift.NewShapes = append(drift.NewShapes, shape)
+		}
+	}
+	for _, shape := range baseline.Shapes {
+		if baselineShapes[shape] {
+			drift.MissingShapes = append(drift.MissingShapes, shape)
+		}
+	}
+
+	// the jaccard index of both shape sets
+	if all := common + len(drift.NewShapes) + len(drift.MissingShapes); all > 0 {
+		drift.Similarity = float64(common) / float64(all)
+	}
+	if drift.Similarity >= threshold {
+		return nil
+	}
+	return &drift
+}
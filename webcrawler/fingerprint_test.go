@@ -0,0 +1,86 @@
+package webcrawler
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLayoutDrift(t *testing.T) {
+	_, report, err := CrawlCurrentWeek(context.Background(), "file://bistro.html")
+	if err != nil || len(report.Fingerprints) != 1 {
+		t.Fatalf("expected a fingerprint of the crawled page but got %+v: %v", report.Fingerprints, err)
+	}
+	baseline := report.Fingerprints[0]
+	page, _ := ioutil.ReadFile("bistro.html")
+
+	fingerprint := func(page string) PageFingerprint {
+		_, report, _ := cgmSource{}.ParseWeek(strings.NewReader(page))
+		return report.Fingerprints[0]
+	}
+
+	t.Run("expect the fingerprint to describe the day and meal nodes", func(t *testing.T) {
+		if baseline.BaselineId() != "cgm-v1" || len(baseline.Shapes) == 0 {
+			t.Fatalf("expected shapes for the baseline cgm-v1 but got %+v", baseline)
+		}
+	})
+
+	t.Run("expect changed texts and styles to keep the fingerprint", func(t *testing.T) {
+		edited := strings.Replace(string(page), "Pizza", "Flammkuchen", -1)
+		edited = strings.Replace(edited, "background-color:greenyellow", "background-color:red", -1)
+		if got := fingerprint(edited); got.Hash != baseline.Hash {
+			t.Fatalf("expected the same fingerprint but got the shapes %v", got.Shapes)
+		}
+	})
+
+	t.Run("expect a renamed meal node to be a drift", func(t *testing.T) {
+		redesigned := strings.Replace(string(page), `id="meal"`, `id="dish"`, -1)
+		drift := DetectDrift(baseline, fingerprint(redesigned), 0.8)
+		if drift == nil || drift.Similarity >= 0.8 || len(drift.MissingShapes) == 0 {
+			t.Fatalf("expected a drift with missing shapes but got %+v", drift)
+		}
+	})
+
+	t.Run("expect a moved day column to be a drift", func(t *testing.T) {
+		wrapped := strings.Replace(string(page), `<div class="base-layer" id="wholeMenu">`, `<div class="wrapper"><div class="base-layer" id="wholeMenu">`, 1)
+		drift := DetectDrift(baseline, fingerprint(wrapped), 0.8)
+		if drift == nil || len(drift.NewShapes) == 0 {
+			t.Fatalf("expected a drift with new shapes but got %+v", drift)
+		}
+	})
+
+	t.Run("expect a page whose days cannot be parsed any more to be fingerprinted as drift", func(t *testing.T) {
+		redesigned := strings.Replace(string(page), `id="day"`, `id="weekday"`, -1)
+		_, report, err := CrawlSource(context.Background(), DefaultSourceName, "file://"+writeTempPage(t, redesigned), "")
+		if err == nil || len(report.Fingerprints) != 1 {
+			t.Fatalf("expected a parse error with a fingerprint but got %+v: %v", report.Fingerprints, err)
+		}
+		if drift := DetectDrift(baseline, report.Fingerprints[0], 0.8); drift == nil {
+			t.Fatalf("expected a drift of the unparseable page")
+		}
+	})
+
+	t.Run("expect a small deviation below the threshold to be tolerated", func(t *testing.T) {
+		withoutLine := strings.Replace(string(page), `<hr class="thinLine" noshade style="width:90%">`, "", -1)
+		if drift := DetectDrift(baseline, fingerprint(withoutLine), 0.8); drift != nil {
+			t.Fatalf("expected no drift but got %+v", drift)
+		}
+	})
+}
+
+// Writes a page to a temporary file that is removed after the test
+func writeTempPage(t *testing.T, page string) string {
+	file, err := ioutil.TempFile("", "page-*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	t.Cleanup(func() { os.Remove(file.Name()) })
+
+	if _, err := file.WriteString(page); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
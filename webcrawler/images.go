@@ -0,0 +1,249 @@
+package webcrawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	// register the decoders of the image formats menu pages use
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Represents a picture of a meal
+type MealImage struct {
+	Id         string   `json:"id,omitempty"`         // the sha256 hash of the image, blank if the image was not downloaded
+	SourceUrl  string   `json:"sourceUrl"`            // where the image was found
+	Width      int      `json:"width,omitempty"`      // the width of the original image in pixels
+	Height     int      `json:"height,omitempty"`     // the height of the original image in pixels
+	Thumbnails []uint64 `json:"thumbnails,omitempty"` // the widths of the generated thumbnails
+}
+
+// Stores downloaded images in a local directory together with jpeg thumbnails in several widths
+// Every image is addressed by the sha256 hash of its content, so an image is stored only once
+type ImageStore struct {
+	directory       string
+	thumbnailWidths []uint64
+	maxPixels       uint64 // the largest width times height of an image that is decoded, zero for no limit
+}
+
+// The store that keeps the images of all crawled meals, nil if images are not downloaded
+var defaultImageStore *ImageStore
+
+// Matches valid image ids, which keeps ids from addressing files outside of the store
+var imageIdPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func init() {
+	cfg := config.Get()
+	if cfg.ImageDirectory == "" {
+		return
+	}
+
+	store, err := NewImageStore(cfg.ImageDirectory, cfg.ImageThumbnailWidths, cfg.ImageMaxPixels)
+	if err != nil {
+		log.Fatal("Failed to create the image store ", err)
+	}
+	defaultImageStore = store
+}
+
+// Returns the store that keeps the images of all crawled meals, nil if images are not downloaded
+func DefaultImageStore() *ImageStore {
+	return defaultImageStore
+}
+
+// Replaces the store that keeps the images of all crawled meals, nil stops downloading images
+func UseImageStore(store *ImageStore) {
+	defaultImageStore = store
+}
+
+// Creates a new store that keeps its images in the specified directory
+// Images with more than the max pixels are rejected, leave it zero to accept images of any size
+// The directory is created if it does not exist yet
+func NewImageStore(directory string, thumbnailWidths []uint64, maxPixels uint64) (*ImageStore, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("creating the image directory %s failed: %w", directory, err)
+	}
+	return &ImageStore{directory: directory, thumbnailWidths: thumbnailWidths, maxPixels: maxPixels}, nil
+}
+
+// Stores a downloaded image and generates its thumbnails
+// Thumbnails are never wider than the original image
+// The size of the image is checked before it is decoded, so a small file that declares huge dimensions
+// cannot exhaust the memory
+// Returns the stored image or an error if the content is no gif, jpeg or png image or has too many pixels
+func (store *ImageStore) Store(content []byte) (MealImage, error) {
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return MealImage{}, fmt.Errorf("decoding the image failed: %w", err)
+	}
+	pixels := uint64(imageConfig.Width) * uint64(imageConfig.Height)
+	if store.maxPixels > 0 && pixels > store.maxPixels {
+		return MealImage{}, fmt.Errorf("the image of %dx%d pixels exceeds the limit of %d pixels",
+			imageConfig.Width, imageConfig.Height, store.maxPixels)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return MealImage{}, fmt.Errorf("decoding the image failed: %w", err)
+	}
+
+	hash := sha256.Sum256(content)
+	stored := MealImage{
+		Id:     hex.EncodeToString(hash[:]),
+		Width:  decoded.Bounds().Dx(),
+		Height: decoded.Bounds().Dy(),
+	}
+
+	if err := writeFileOnce(store.imagePath(stored.Id, 0), func() ([]byte, error) { return content, nil }); err != nil {
+		return stored, err
+	}
+
+	for _, width := range store.thumbnailWidths {
+		if width == 0 || int(width) > stored.Width {
+			continue
+		}
+		err := writeFileOnce(store.imagePath(stored.Id, width), func() ([]byte, error) {
+			var thumbnail bytes.Buffer
+			err := jpeg.Encode(&thumbnail, scaleToWidth(decoded, int(width)), &jpeg.Options{Quality: 85})
+			return thumbnail.Bytes(), err
+		})
+		if err != nil {
+			return stored, err
+		}
+		stored.Thumbnails = append(stored.Thumbnails, width)
+	}
+
+	return stored, nil
+}
+
+// Builds the file path of an image or its thumbnail
+// Leave the width zero for the original image
+// Returns an error if no such image or thumbnail is stored
+func (store *ImageStore) Path(imageId string, width uint64) (string, error) {
+	if !imageIdPattern.MatchString(imageId) {
+		return "", fmt.Errorf("invalid image id '%s'", imageId)
+	}
+
+	imagePath := store.imagePath(imageId, width)
+	if _, err := os.Stat(imagePath); err != nil {
+		return "", err
+	}
+	return imagePath, nil
+}
+
+// Builds the file path of an image or its thumbnail, images are spread over sub directories named like their first byte
+func (store *ImageStore) imagePath(imageId string, width uint64) string {
+	name := imageId
+	if width > 0 {
+		name = fmt.Sprintf("%s-%d.jpg", imageId, width)
+	}
+	return filepath.Join(store.directory, imageId[:2], name)
+}
+
+// Writes the content to a file unless the file exists already
+// Concurrent jobs that download the same image each write a temporary file of their own, the last rename wins
+func writeFileOnce(path string, content func() ([]byte, error)) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	data, err := content()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, data)
+}
+
+// Scales an image down to the width and keeps its aspect ratio
+// Every pixel of the result is the average of the pixels it covers in the source image
+func scaleToWidth(source image.Image, width int) image.Image {
+	bounds := source.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		fromY, toY := bounds.Min.Y+y*bounds.Dy()/height, bounds.Min.Y+(y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			fromX, toX := bounds.Min.X+x*bounds.Dx()/width, bounds.Min.X+(x+1)*bounds.Dx()/width
+
+			var r, g, b, a, count uint64
+			for sourceY := fromY; sourceY < toY || sourceY == fromY; sourceY++ {
+				for sourceX := fromX; sourceX < toX || sourceX == fromX; sourceX++ {
+					pixelR, pixelG, pixelB, pixelA := source.At(sourceX, sourceY).RGBA()
+					r, g, b, a = r+uint64(pixelR), g+uint64(pixelG), b+uint64(pixelB), a+uint64(pixelA)
+					count++
+				}
+			}
+
+			scaled.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return scaled
+}
+
+// Resolves the image urls of all meals against the location of the week page
+// and downloads the images into the image store, if images are stored
+// Every image is downloaded only once, images that cannot be stored are reported as warnings
+func downloadImages(ctx context.Context, location string, meals []Meal, report *ParseReport) {
+	base, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(base.Scheme, "http") {
+		return
+	}
+
+	stored := make(map[string]MealImage)
+	for i := range meals {
+		for j := range meals[i].Images {
+			mealImage := &meals[i].Images[j]
+			link, err := url.Parse(mealImage.SourceUrl)
+			if err != nil {
+				report.addWarning("the image url '%s' of the meal '%s' is invalid", mealImage.SourceUrl, meals[i].Name)
+				continue
+			}
+			mealImage.SourceUrl = base.ResolveReference(link).String()
+			if defaultImageStore == nil {
+				continue
+			}
+
+			storedImage, found := stored[mealImage.SourceUrl]
+			if !found {
+				storedImage, err = downloadImage(ctx, mealImage.SourceUrl)
+				if err != nil {
+					report.addWarning("downloading the image %s failed: %s", mealImage.SourceUrl, err)
+					continue
+				}
+				stored[mealImage.SourceUrl] = storedImage
+			}
+			storedImage.SourceUrl = mealImage.SourceUrl
+			*mealImage = storedImage
+		}
+	}
+}
+
+// Downloads an image with the fetcher of all menu sources and stores it in the image store
+func downloadImage(ctx context.Context, imageUrl string) (MealImage, error) {
+	content, err := defaultFetcher.FetchUnconditional(ctx, imageUrl)
+	if err != nil {
+		return MealImage{}, err
+	}
+	return defaultImageStore.Store(content)
+}
@@ -0,0 +1,149 @@
+package webcrawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Encodes a png image of the specified size in a single color
+func pngImage(width int, height int) []byte {
+	picture := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			picture.Set(x, y, color.RGBA{R: 200, G: 120, B: 40, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	png.Encode(&encoded, picture)
+	return encoded.Bytes()
+}
+
+func TestImageStore(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "images")
+	defer os.RemoveAll(directory)
+	store, _ := NewImageStore(directory, []uint64{50, 400}, 100000)
+
+	stored, err := store.Store(pngImage(200, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("expect the size of the original image", func(t *testing.T) {
+		if len(stored.Id) != 64 || stored.Width != 200 || stored.Height != 100 {
+			t.Fatalf("expected a 200x100 image with a sha256 id but got %+v", stored)
+		}
+	})
+
+	t.Run("expect only thumbnails smaller than the original", func(t *testing.T) {
+		if !reflect.DeepEqual(stored.Thumbnails, []uint64{50}) {
+			t.Fatalf("expected a single thumbnail of width 50 but got %v", stored.Thumbnails)
+		}
+	})
+
+	t.Run("expect the thumbnail to keep the aspect ratio", func(t *testing.T) {
+		thumbnailPath, err := store.Path(stored.Id, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, _ := os.Open(thumbnailPath)
+		defer file.Close()
+		config, format, err := image.DecodeConfig(file)
+		if err != nil || format != "jpeg" || config.Width != 50 || config.Height != 25 {
+			t.Fatalf("expected a 50x25 jpeg but got a %dx%d %s: %v", config.Width, config.Height, format, err)
+		}
+	})
+
+	t.Run("expect the same image to be stored once", func(t *testing.T) {
+		again, err := store.Store(pngImage(200, 100))
+		if err != nil || again.Id != stored.Id {
+			t.Fatalf("expected the id %s but got %s: %v", stored.Id, again.Id, err)
+		}
+	})
+
+	t.Run("expect an error for content that is no image", func(t *testing.T) {
+		if _, err := store.Store([]byte("<html></html>")); err == nil {
+			t.Fatalf("expected an error for html content")
+		}
+	})
+
+	t.Run("expect an error for an image with more pixels than the limit", func(t *testing.T) {
+		if _, err := store.Store(pngImage(400, 300)); err == nil {
+			t.Fatalf("expected an error for an image of 120000 pixels")
+		}
+	})
+
+	t.Run("expect an error for an id outside of the store", func(t *testing.T) {
+		if _, err := store.Path("../../etc/passwd", 0); err == nil {
+			t.Fatalf("expected an error for an invalid id")
+		}
+	})
+}
+
+func TestImageDownloading(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/images/missing.png" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(pngImage(320, 240))
+	}))
+	defer server.Close()
+
+	directory, _ := ioutil.TempDir("", "images")
+	defer os.RemoveAll(directory)
+	defaultImageStore, _ = NewImageStore(directory, []uint64{160}, 0)
+	defer func() { defaultImageStore = nil }()
+
+	meals := []Meal{
+		{Name: "Pizza", Images: []MealImage{{SourceUrl: "images/pizza.png"}}},
+		{Name: "Pizza", Images: []MealImage{{SourceUrl: "/images/pizza.png"}}},
+		{Name: "Suppe", Images: []MealImage{{SourceUrl: "images/missing.png"}}},
+	}
+	report := ParseReport{}
+	downloadImages(context.Background(), server.URL+"/index.php", meals, &report)
+
+	t.Run("expect the stored image on the meal", func(t *testing.T) {
+		pizza := meals[0].Images[0]
+		if pizza.Id == "" || pizza.SourceUrl != server.URL+"/images/pizza.png" || pizza.Width != 320 {
+			t.Fatalf("expected the stored pizza image but got %+v", pizza)
+		}
+	})
+
+	t.Run("expect an image to be downloaded once", func(t *testing.T) {
+		if requests != 2 || meals[1].Images[0].Id != meals[0].Images[0].Id {
+			t.Fatalf("expected 2 requests and the same image but got %d requests and %+v", requests, meals[1].Images)
+		}
+	})
+
+	t.Run("expect a missing image to be reported", func(t *testing.T) {
+		if meals[2].Images[0].Id != "" || len(report.Warnings) != 1 {
+			t.Fatalf("expected an image without id and a warning but got %+v and %v", meals[2].Images, report.Warnings)
+		}
+	})
+}
+
+func TestImageParsing(t *testing.T) {
+	html := `<div id="meal"><img src="placeholder.gif" data-src="pizza.jpg"><img src="salad.jpg"><img src="data:image/gif;base64,R0lGOD"></div>`
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+
+	t.Run("expect lazy loaded and inline images to be handled", func(t *testing.T) {
+		got := parseImages(doc.Find("div#meal"), "img")
+		want := []MealImage{{SourceUrl: "pizza.jpg"}, {SourceUrl: "salad.jpg"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the images %+v but got %+v", want, got)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package webcrawler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits the requests sent to every host with a token bucket of its own
+// A bucket holds up to burst tokens and gains one token per interval, every request takes one token
+type hostLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	burst    float64
+	buckets  map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Creates a limiter that allows the specified requests per second to every host
+// A rate that is not positive only limits hosts that ask for a crawl delay
+func newHostLimiter(requestsPerSecond float64, burst uint64) *hostLimiter {
+	limiter := &hostLimiter{
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+	if requestsPerSecond > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	if burst == 0 {
+		limiter.burst = 1
+	}
+	return limiter
+}
+
+// Waits until a request to the host is allowed or the context is done
+// A crawl delay of the host replaces the interval if it is longer and allows no bursts
+func (limiter *hostLimiter) wait(ctx context.Context, host string, crawlDelay time.Duration) error {
+	delay := limiter.reserve(host, crawlDelay, time.Now())
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Takes a token from the bucket of the host
+// Returns the time to wait until the token is available
+func (limiter *hostLimiter) reserve(host string, crawlDelay time.Duration, now time.Time) time.Duration {
+	interval, burst := limiter.interval, limiter.burst
+	if crawlDelay > interval {
+		interval, burst = crawlDelay, 1
+	}
+	if interval <= 0 {
+		return 0
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	bucket, found := limiter.buckets[host]
+	if !found {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		limiter.buckets[host] = bucket
+	}
+
+	bucket.tokens += float64(now.Sub(bucket.updated)) / float64(interval)
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.updated = now
+
+	// the token is taken right away, so later requests queue up behind it
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens * float64(interval))
+}
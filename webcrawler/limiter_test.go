@@ -0,0 +1,62 @@
+package webcrawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("expect a burst to pass without waiting", func(t *testing.T) {
+		limiter := newHostLimiter(1, 2)
+		if limiter.reserve("bistro", 0, now) != 0 || limiter.reserve("bistro", 0, now) != 0 {
+			t.Fatalf("expected the first two requests to pass")
+		}
+		if delay := limiter.reserve("bistro", 0, now); delay != time.Second {
+			t.Fatalf("expected the third request to wait a second but got %s", delay)
+		}
+	})
+
+	t.Run("expect waiting requests to queue up", func(t *testing.T) {
+		limiter := newHostLimiter(2, 1)
+		limiter.reserve("bistro", 0, now)
+		limiter.reserve("bistro", 0, now)
+		if delay := limiter.reserve("bistro", 0, now); delay != time.Second {
+			t.Fatalf("expected the third request to wait a second but got %s", delay)
+		}
+	})
+
+	t.Run("expect every host to have a bucket of its own", func(t *testing.T) {
+		limiter := newHostLimiter(1, 1)
+		limiter.reserve("bistro", 0, now)
+		if delay := limiter.reserve("canteen", 0, now); delay != 0 {
+			t.Fatalf("expected another host to pass but got a delay of %s", delay)
+		}
+	})
+
+	t.Run("expect tokens to be refilled over time", func(t *testing.T) {
+		limiter := newHostLimiter(1, 1)
+		limiter.reserve("bistro", 0, now)
+		if delay := limiter.reserve("bistro", 0, now.Add(time.Second)); delay != 0 {
+			t.Fatalf("expected a refilled token but got a delay of %s", delay)
+		}
+	})
+
+	t.Run("expect a longer crawl delay to replace the rate", func(t *testing.T) {
+		limiter := newHostLimiter(10, 5)
+		limiter.reserve("bistro", 3*time.Second, now)
+		if delay := limiter.reserve("bistro", 3*time.Second, now); delay != 3*time.Second {
+			t.Fatalf("expected a delay of 3s but got %s", delay)
+		}
+	})
+
+	t.Run("expect no limit without a rate and crawl delay", func(t *testing.T) {
+		limiter := newHostLimiter(0, 0)
+		for i := 0; i < 10; i++ {
+			if delay := limiter.reserve("bistro", 0, now); delay != 0 {
+				t.Fatalf("expected no delay but got %s", delay)
+			}
+		}
+	})
+}
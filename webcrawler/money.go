@@ -0,0 +1,217 @@
+package webcrawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The currency of all prices that do not state another one
+const DefaultCurrency = "EUR"
+
+// A currency symbol and the ISO 4217 code it stands for
+type currencySymbol struct {
+	symbol string
+	code   string
+}
+
+// The known currency symbols, longer symbols first, so a symbol that contains another one is matched as a whole
+var currencySymbols = []currencySymbol{
+	{"&euro;", "EUR"},
+	{"US$", "USD"},
+	{"CA$", "CAD"},
+	{"€", "EUR"},
+	{"$", "USD"},
+	{"£", "GBP"},
+}
+
+// Represents an amount of money as integer cents, so prices can be compared and summed without rounding noise
+// Amounts in the default currency are serialized as plain decimal numbers to stay compatible with the former float prices,
+// amounts in other currencies as object with the decimal amount and the currency
+type Money struct {
+	Cents    int64  // the amount in the smallest unit of the currency
+	Currency string // the ISO 4217 code of the currency, e.g. EUR
+}
+
+// The serialized form of an amount in a currency other than the default currency
+type moneyObject struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// Creates an amount of cents in the specified currency
+// A blank currency falls back to the default currency
+func NewMoney(cents int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Cents: cents, Currency: currency}
+}
+
+// Creates an amount of euro cents
+func EuroCents(cents int64) Money {
+	return NewMoney(cents, DefaultCurrency)
+}
+
+// Parses a price like '3,97 €', '3.97' or 'CHF 4,50' without losing precision
+// Prices without a currency are in the default currency
+// Returns an error if the price is blank, has more than two fraction digits or is not a number at all
+func ParseMoney(priceString string) (Money, error) {
+	amount, currency := splitCurrency(priceString)
+	if amount == "" {
+		return Money{}, fmt.Errorf("missing price '%s'", priceString)
+	}
+
+	cents, err := parseCents(amount)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid price '%s': %w", priceString, err)
+	}
+	return NewMoney(cents, currency), nil
+}
+
+// Separates the currency symbol or code from the amount of a price
+// Returns the trimmed amount and the ISO 4217 code of the currency, which is blank if the price states none
+func splitCurrency(priceString string) (amount string, currency string) {
+	amount = strings.TrimSpace(priceString)
+	for _, currency := range currencySymbols {
+		if strings.Contains(amount, currency.symbol) {
+			return strings.TrimSpace(strings.Replace(amount, currency.symbol, "", -1)), currency.code
+		}
+	}
+
+	fields := strings.Fields(amount)
+	if len(fields) == 2 {
+		for i, field := range fields {
+			if isCurrencyCode(field) {
+				return fields[1-i], field
+			}
+		}
+	}
+	return amount, ""
+}
+
+// Tests if a string is a three letter currency code like EUR
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, letter := range code {
+		if letter < 'A' || letter > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses a decimal amount with a comma or a dot as decimal separator into cents
+// Thousands separators are accepted if both separators are used, e.g. 1.234,50
+// An amount without any digits, e.g. a lone minus sign, is no number and never parsed as zero
+func parseCents(amount string) (int64, error) {
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimPrefix(amount, "-")
+
+	integerPart, fractionPart := amount, ""
+	if separator := strings.LastIndexAny(amount, ",."); separator >= 0 {
+		integerPart, fractionPart = amount[:separator], amount[separator+1:]
+		thousandsSeparator := "."
+		if amount[separator] == '.' {
+			thousandsSeparator = ","
+		}
+		integerPart = strings.Replace(integerPart, thousandsSeparator, "", -1)
+	}
+
+	if integerPart == "" && fractionPart == "" {
+		return 0, fmt.Errorf("no digits")
+	}
+	if len(fractionPart) > 2 {
+		return 0, fmt.Errorf("more than two fraction digits")
+	}
+	if integerPart == "" {
+		integerPart = "0"
+	}
+	if !isDigits(integerPart) || !isDigits(fractionPart) {
+		return 0, fmt.Errorf("not a decimal number")
+	}
+
+	units, err := strconv.ParseInt(integerPart, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	cents, _ := strconv.ParseInt((fractionPart + "00")[:2], 10, 64)
+
+	cents += units * 100
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// Tests if a string consists of decimal digits only
+func isDigits(digits string) bool {
+	for _, digit := range digits {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Subtracts another amount from this amount
+// The result keeps the currency of this amount or of the other amount if this one has none
+func (money Money) Sub(other Money) Money {
+	currency := money.Currency
+	if currency == "" {
+		currency = other.Currency
+	}
+	return NewMoney(money.Cents-other.Cents, currency)
+}
+
+// Formats the amount as decimal number with two fraction digits, e.g. 3.97
+func (money Money) Decimal() string {
+	sign, cents := "", money.Cents
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Formats the amount with its currency, e.g. 3.97 EUR
+func (money Money) String() string {
+	return money.Decimal() + " " + NewMoney(0, money.Currency).Currency
+}
+
+// Serializes amounts in the default currency as decimal number and all other amounts as object
+func (money Money) MarshalJSON() ([]byte, error) {
+	if money.Currency == "" || money.Currency == DefaultCurrency {
+		return []byte(money.Decimal()), nil
+	}
+	return json.Marshal(moneyObject{Amount: json.Number(money.Decimal()), Currency: money.Currency})
+}
+
+// Deserializes an amount from a decimal number in the default currency or from an object with amount and currency
+// Numbers with float noise of formerly stored prices are rounded to cents
+func (money *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var object moneyObject
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+	} else {
+		object.Amount = json.Number(data)
+	}
+
+	amount, err := object.Amount.Float64()
+	if err != nil {
+		return fmt.Errorf("invalid amount '%s': %w", object.Amount, err)
+	}
+	*money = NewMoney(int64(math.Round(amount*100)), object.Currency)
+	return nil
+}
@@ -0,0 +1,90 @@
+package webcrawler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		price string
+		want  Money
+	}{
+		{"3,97 €", EuroCents(397)},
+		{"3,97&euro;", EuroCents(397)},
+		{" 4.1 ", EuroCents(410)},
+		{"12", EuroCents(1200)},
+		{"1.234,50 €", EuroCents(123450)},
+		{"-0,05", EuroCents(-5)},
+		{"CHF 4,50", NewMoney(450, "CHF")},
+		{"CA$ 4.50", NewMoney(450, "CAD")},
+		{"US$4.50", NewMoney(450, "USD")},
+	}
+
+	for _, test := range tests {
+		t.Run("expect "+test.price+" to be parsed exactly", func(t *testing.T) {
+			got, err := ParseMoney(test.price)
+			if err != nil || got != test.want {
+				t.Fatalf("expected %v but got %v: %v", test.want, got, err)
+			}
+		})
+	}
+
+	for _, price := range []string{"", "€", "drei Euro", "3,975 €", "3,9a", "-", "-,", ".", "- €"} {
+		t.Run("expect an error for '"+price+"'", func(t *testing.T) {
+			if got, err := ParseMoney(price); err == nil {
+				t.Fatalf("expected an error but got %v", got)
+			}
+		})
+	}
+}
+
+func TestCurrencySymbols(t *testing.T) {
+	t.Run("expect every symbol to be listed before the symbols it contains", func(t *testing.T) {
+		for i, currency := range currencySymbols {
+			for _, earlier := range currencySymbols[:i] {
+				if strings.Contains(currency.symbol, earlier.symbol) {
+					t.Fatalf("expected %s to be listed before %s", currency.symbol, earlier.symbol)
+				}
+			}
+		}
+	})
+}
+
+func TestMoneyJson(t *testing.T) {
+
+	t.Run("expect euros to be serialized as number", func(t *testing.T) {
+		data, _ := json.Marshal(Supplement{Name: "Reis", Price: EuroCents(390)})
+		if string(data) != `{"name":"Reis","price":3.90}` {
+			t.Fatalf("expected the price as number but got %s", data)
+		}
+	})
+
+	t.Run("expect formerly stored float prices to be rounded to cents", func(t *testing.T) {
+		var supplement Supplement
+		if err := json.Unmarshal([]byte(`{"name":"Reis","price":3.9699999999999998}`), &supplement); err != nil {
+			t.Fatal(err)
+		}
+		if supplement.Price != EuroCents(397) {
+			t.Fatalf("expected 3.97 EUR but got %v", supplement.Price)
+		}
+	})
+
+	t.Run("expect other currencies to survive a round trip", func(t *testing.T) {
+		var got Money
+		data, _ := json.Marshal(NewMoney(450, "CHF"))
+		if err := json.Unmarshal(data, &got); err != nil || got != NewMoney(450, "CHF") {
+			t.Fatalf("expected 4.50 CHF but got %v from %s: %v", got, data, err)
+		}
+	})
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	if delta := EuroCents(330).Sub(EuroCents(310)); delta != EuroCents(20) || delta.String() != "0.20 EUR" {
+		t.Fatalf("expected a delta of 0.20 EUR but got %v", delta)
+	}
+	if delta := (Money{}).Sub(EuroCents(420)); delta != EuroCents(-420) || delta.Decimal() != "-4.20" {
+		t.Fatalf("expected a delta of -4.20 EUR but got %v", delta)
+	}
+}
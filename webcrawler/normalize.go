@@ -0,0 +1,151 @@
+package webcrawler
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// The minimum similarity of two normalized names to treat them as the same meal
+const nameSimilarityThreshold = 0.85
+
+// Replaces typographic punctuation by its plain counterpart
+var punctuationReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "´", "'", "`", "'",
+	"“", "\"", "”", "\"", "„", "\"", "«", "\"", "»", "\"",
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-",
+	"…", "...",
+	" ,", ",", " .", ".", " ;", ";", " :", ":", " !", "!", " ?", "?",
+	"( ", "(", " )", ")",
+)
+
+// Generates the identifier of a meal
+// The id is composed like this: sha1( source + "-" + date + normalized name )
+// Every source has meals of its own, so sources serving the same dish at the same date do not overwrite each other
+func MealId(sourceName string, date string, name string) string {
+	return toSha1(sourceName + "-" + date + NormalizeMealName(name))
+}
+
+// Removes leading and trailing whitespace and collapses all inner whitespace into single spaces
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Normalizes a meal name so that whitespace, case, accents and punctuation edits result in the same name
+// Returns the name trimmed, with collapsed whitespace, folded unicode and plain punctuation
+func NormalizeMealName(name string) string {
+	name = norm.NFKC.String(name)
+	name = strings.ToLower(name)
+
+	withoutMarks := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if folded, _, err := transform.String(withoutMarks, name); err == nil {
+		name = folded
+	}
+
+	name = cleanName(name)
+	return punctuationReplacer.Replace(name)
+}
+
+// Keeps the identity of meals whose name was edited since the previous crawl
+// Every crawled meal without a stored counterpart takes over the id of the most similar stored meal
+// of the same date, as long as that stored meal was not crawled again and is similar enough
+// Returns the crawled meals with their matched ids
+func MatchIdentities(storedMeals []Meal, crawledMeals []Meal) []Meal {
+	crawledIds := make(map[string]bool)
+	for _, meal := range crawledMeals {
+		crawledIds[meal.Id] = true
+	}
+
+	// stored meals that would vanish without a match
+	storedIds := make(map[string]bool)
+	unmatched := make(map[string][]Meal)
+	for _, meal := range storedMeals {
+		storedIds[meal.Id] = true
+		if !crawledIds[meal.Id] {
+			unmatched[meal.Date] = append(unmatched[meal.Date], meal)
+		}
+	}
+
+	matchedMeals := make([]Meal, len(crawledMeals))
+	for i, meal := range crawledMeals {
+		matchedMeals[i] = meal
+		candidates := unmatched[meal.Date]
+		if storedIds[meal.Id] || len(candidates) == 0 {
+			continue
+		}
+
+		best, similarity := mostSimilarMeal(meal, candidates)
+		if similarity >= nameSimilarityThreshold {
+			matchedMeals[i].Id = candidates[best].Id
+
+			remaining := make([]Meal, 0, len(candidates)-1)
+			remaining = append(remaining, candidates[:best]...)
+			unmatched[meal.Date] = append(remaining, candidates[best+1:]...)
+		}
+	}
+
+	return matchedMeals
+}
+
+// Finds the candidate whose normalized name is the most similar to the name of the meal
+// Returns the index of the candidate and the similarity between 0 and 1
+func mostSimilarMeal(meal Meal, candidates []Meal) (best int, bestSimilarity float64) {
+	name := NormalizeMealName(meal.Name)
+	for i, candidate := range candidates {
+		similarity := nameSimilarity(name, NormalizeMealName(candidate.Name))
+		if similarity > bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+// Calculates the similarity of two names based on their levenshtein distance
+// Returns 1 for equal names and 0 for completely different names
+func nameSimilarity(a string, b string) float64 {
+	aRunes, bRunes := []rune(a), []rune(b)
+	longest := len(aRunes)
+	if len(bRunes) > longest {
+		longest = len(bRunes)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(aRunes, bRunes))/float64(longest)
+}
+
+// Calculates the number of single rune edits that turn one name into the other
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minOf(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// Returns the smallest of three numbers
+func minOf(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
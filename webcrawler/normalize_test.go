@@ -0,0 +1,95 @@
+package webcrawler
+
+import "testing"
+
+func TestNormalizeMealName(t *testing.T) {
+	tests := []struct {
+		name   string
+		edited string
+	}{
+		{"Gebratene Nudeln mit Gemüse", "  Gebratene  Nudeln mit\tGemüse "},
+		{"Gebratene Nudeln mit Gemüse", "gebratene nudeln MIT gemüse"},
+		{"Crème brûlée", "Creme brulee"},
+		{"Kartoffeln \"Bauernart\" – hausgemacht", "Kartoffeln „Bauernart“ - hausgemacht"},
+		{"Salat, Dressing", "Salat , Dressing"},
+	}
+
+	for _, test := range tests {
+		t.Run("expect the same name for "+test.edited, func(t *testing.T) {
+			if NormalizeMealName(test.name) != NormalizeMealName(test.edited) {
+				t.Fatalf("expected %q and %q to be normalized to the same name but got %q and %q",
+					test.name, test.edited, NormalizeMealName(test.name), NormalizeMealName(test.edited))
+			}
+			if MealId("cgm", "2020-06-08", test.name) != MealId("cgm", "2020-06-08", test.edited) {
+				t.Fatalf("expected %q and %q to have the same id", test.name, test.edited)
+			}
+		})
+	}
+
+	t.Run("expect different meals to keep different names", func(t *testing.T) {
+		if NormalizeMealName("Pizza Hawaii") == NormalizeMealName("Pizza Salami") {
+			t.Fatal("expected different meals to be normalized to different names")
+		}
+	})
+
+	t.Run("expect the same meal of different sources to have different ids", func(t *testing.T) {
+		if MealId("cgm", "2020-06-08", "Pizza Hawaii") == MealId("other", "2020-06-08", "Pizza Hawaii") {
+			t.Fatal("expected every source to have meals of its own")
+		}
+	})
+}
+
+func TestMatchIdentities(t *testing.T) {
+	stored := Meal{Id: "stored", Date: "2020-06-08", Name: "Spaghetti Bolognese"}
+	other := Meal{Id: "other", Date: "2020-06-08", Name: "Gemüsesuppe"}
+
+	t.Run("expect a meal with a typo fix to keep its id", func(t *testing.T) {
+		fixed := Meal{Date: stored.Date, Name: "Spaghetti Bolognaise"}
+		fixed.Id = MealId("cgm", fixed.Date, fixed.Name)
+
+		matched := MatchIdentities([]Meal{stored, other}, []Meal{fixed, other})
+		if matched[0].Id != stored.Id || matched[0].Name != fixed.Name {
+			t.Fatalf("expected the fixed meal to keep the id %s but got %+v", stored.Id, matched[0])
+		}
+	})
+
+	t.Run("expect a swapped meal to get a new id", func(t *testing.T) {
+		swapped := Meal{Id: "swapped", Date: stored.Date, Name: "Pizza Margherita"}
+
+		matched := MatchIdentities([]Meal{stored, other}, []Meal{swapped, other})
+		if matched[0].Id != swapped.Id {
+			t.Fatalf("expected the swapped meal to keep its own id but got %s", matched[0].Id)
+		}
+	})
+
+	t.Run("expect a meal of another date not to be matched", func(t *testing.T) {
+		nextDay := Meal{Id: "next", Date: "2020-06-09", Name: stored.Name}
+
+		matched := MatchIdentities([]Meal{stored}, []Meal{nextDay})
+		if matched[0].Id != nextDay.Id {
+			t.Fatalf("expected the meal of the next day to keep its own id but got %s", matched[0].Id)
+		}
+	})
+
+	t.Run("expect a stored meal to be matched only once", func(t *testing.T) {
+		first := Meal{Id: "first", Date: stored.Date, Name: "Spaghetti Bolognaise"}
+		second := Meal{Id: "second", Date: stored.Date, Name: "Spagetti Bolognese"}
+
+		matched := MatchIdentities([]Meal{stored}, []Meal{first, second})
+		if matched[0].Id != stored.Id || matched[1].Id != second.Id {
+			t.Fatalf("expected only the first meal to take over the id but got %s and %s", matched[0].Id, matched[1].Id)
+		}
+	})
+}
+
+func TestNameSimilarity(t *testing.T) {
+	if similarity := nameSimilarity("abc", "abc"); similarity != 1 {
+		t.Fatalf("expected equal names to be similar by 1 but got %f", similarity)
+	}
+	if distance := levenshtein([]rune("kitten"), []rune("sitting")); distance != 3 {
+		t.Fatalf("expected a distance of 3 but got %d", distance)
+	}
+	if similarity := nameSimilarity("", ""); similarity != 1 {
+		t.Fatalf("expected empty names to be similar by 1 but got %f", similarity)
+	}
+}
@@ -0,0 +1,228 @@
+package webcrawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Represents the nutrition of a meal, every value is only present if the source lists it
+type Nutrition struct {
+	Kcal          *float64 `json:"kcal,omitempty"`          // the energy in kilocalories
+	Protein       *float64 `json:"protein,omitempty"`       // the protein in grams
+	Fat           *float64 `json:"fat,omitempty"`           // the fat in grams
+	Carbohydrates *float64 `json:"carbohydrates,omitempty"` // the carbohydrates in grams
+}
+
+// Holds the texts that precede or follow the nutrition values of a page, e.g. kcal or Eiweiß
+type NutritionLabels struct {
+	Kcal          string `yaml:"kcal"`
+	Protein       string `yaml:"protein"`
+	Fat           string `yaml:"fat"`
+	Carbohydrates string `yaml:"carbohydrates"`
+}
+
+// Matches decimal numbers with a point or a comma, e.g. 12,5
+var nutritionNumber = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+
+// Parses the nutrition values from the texts of all nodes the selector finds within the selection
+// Every value is the number next to its label, the first node that holds a label wins
+// Returns nil if no value was found
+func parseNutrition(selection *goquery.Selection, selector string, labels NutritionLabels) *Nutrition {
+	if selector == "" {
+		return nil
+	}
+
+	nutrition := Nutrition{}
+	values := []struct {
+		label string
+		value **float64
+	}{
+		{labels.Kcal, &nutrition.Kcal},
+		{labels.Protein, &nutrition.Protein},
+		{labels.Fat, &nutrition.Fat},
+		{labels.Carbohydrates, &nutrition.Carbohydrates},
+	}
+
+	selection.Find(selector).Each(func(i int, nodeSelection *goquery.Selection) {
+		text := strings.ToLower(nodeSelection.Text())
+		for _, v := range values {
+			if v.label == "" || *v.value != nil {
+				continue
+			}
+			if value, found := numberNextTo(text, strings.ToLower(v.label)); found {
+				*v.value = &value
+			}
+		}
+	})
+
+	if nutrition.isEmpty() {
+		return nil
+	}
+	return &nutrition
+}
+
+// Finds the number closest to the first occurrence of the label in the text
+func numberNextTo(text string, label string) (float64, bool) {
+	labelStart := strings.Index(text, label)
+	if labelStart < 0 {
+		return 0, false
+	}
+	labelEnd := labelStart + len(label)
+
+	closest, closestDistance := "", len(text)+1
+	for _, position := range nutritionNumber.FindAllStringIndex(text, -1) {
+		distance := position[0] - labelEnd
+		if position[1] <= labelStart {
+			distance = labelStart - position[1]
+		}
+		if distance >= 0 && distance < closestDistance {
+			closest, closestDistance = text[position[0]:position[1]], distance
+		}
+	}
+
+	if closest == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.Replace(closest, ",", ".", 1), 64)
+	return value, err == nil
+}
+
+func (nutrition Nutrition) isEmpty() bool {
+	return nutrition.Kcal == nil && nutrition.Protein == nil && nutrition.Fat == nil && nutrition.Carbohydrates == nil
+}
+
+// Fills the values the nutrition lacks with the values of another nutrition
+// Returns the combined nutrition, which is nil if both are nil
+func (nutrition *Nutrition) complete(other *Nutrition) *Nutrition {
+	if nutrition == nil {
+		return other
+	}
+	if other == nil {
+		return nutrition
+	}
+
+	combined := *nutrition
+	if combined.Kcal == nil {
+		combined.Kcal = other.Kcal
+	}
+	if combined.Protein == nil {
+		combined.Protein = other.Protein
+	}
+	if combined.Fat == nil {
+		combined.Fat = other.Fat
+	}
+	if combined.Carbohydrates == nil {
+		combined.Carbohydrates = other.Carbohydrates
+	}
+	return &combined
+}
+
+// The nutrition of a detail page or the reason why it could not be read
+type detailResult struct {
+	nutrition  *Nutrition
+	snapshotId string // the archived detail page, blank if archiving is disabled or failed
+	archiveErr error
+	err        error
+}
+
+// Follows the detail links of all meals and completes their nutrition with the values of the detail pages
+// Links are resolved against the location of the week page, every detail page is fetched only once
+// At most the configured number of detail pages are fetched at the same time
+// Detail pages that cannot be read are reported as warnings and leave the meals as they are
+func followDetailLinks(ctx context.Context, sourceName string, source DetailSource, location string, meals []Meal, report *ParseReport) {
+	base, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(base.Scheme, "http") {
+		for _, meal := range meals {
+			if meal.DetailUrl != "" {
+				report.addWarning("detail pages can only be followed from urls, not from %s", location)
+				return
+			}
+		}
+		return
+	}
+
+	// meals of several days may link the same detail page
+	mealIndexes := make(map[string][]int)
+	detailUrls := make([]string, 0)
+	for i := range meals {
+		if meals[i].DetailUrl == "" {
+			continue
+		}
+		link, err := url.Parse(meals[i].DetailUrl)
+		if err != nil {
+			report.addWarning("the detail link '%s' of the meal '%s' is invalid", meals[i].DetailUrl, meals[i].Name)
+			continue
+		}
+
+		meals[i].DetailUrl = base.ResolveReference(link).String()
+		if _, seen := mealIndexes[meals[i].DetailUrl]; !seen {
+			detailUrls = append(detailUrls, meals[i].DetailUrl)
+		}
+		mealIndexes[meals[i].DetailUrl] = append(mealIndexes[meals[i].DetailUrl], i)
+	}
+
+	results := fetchDetailPages(ctx, sourceName, source, detailUrls, detailPageConcurrency())
+	for i, detailUrl := range detailUrls {
+		if results[i].archiveErr != nil {
+			report.addWarning("archiving the detail page %s failed: %s", detailUrl, results[i].archiveErr)
+		} else if results[i].snapshotId != "" {
+			report.DetailPageIds = append(report.DetailPageIds, results[i].snapshotId)
+		}
+		if results[i].err != nil {
+			report.addWarning("following the detail page %s failed: %s", detailUrl, results[i].err)
+			continue
+		}
+		for _, mealIndex := range mealIndexes[detailUrl] {
+			meals[mealIndex].Nutrition = meals[mealIndex].Nutrition.complete(results[i].nutrition)
+		}
+	}
+}
+
+// Fetches, archives and parses the detail pages with a bounded number of concurrent requests
+// Returns the result of every page in the order of the urls
+func fetchDetailPages(ctx context.Context, sourceName string, source DetailSource, detailUrls []string, concurrency int) []detailResult {
+	results := make([]detailResult, len(detailUrls))
+	semaphore := make(chan struct{}, concurrency)
+
+	var waitGroup sync.WaitGroup
+	for i, detailUrl := range detailUrls {
+		waitGroup.Add(1)
+		go func(i int, detailUrl string) {
+			defer waitGroup.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+
+			body, err := defaultFetcher.FetchUnconditional(ctx, detailUrl)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].snapshotId, results[i].archiveErr = archiveDetailPage(sourceName, body)
+			results[i].nutrition, results[i].err = source.ParseDetail(bytes.NewReader(body))
+		}(i, detailUrl)
+	}
+	waitGroup.Wait()
+
+	return results
+}
+
+// The number of detail pages that are fetched at the same time, at least one
+func detailPageConcurrency() int {
+	if concurrency := config.Get().DetailPageConcurrency; concurrency > 0 {
+		return int(concurrency)
+	}
+	return 1
+}
@@ -0,0 +1,121 @@
+package webcrawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var germanNutritionLabels = NutritionLabels{Kcal: "kcal", Protein: "Eiweiß", Fat: "Fett", Carbohydrates: "Kohlenhydrate"}
+
+func TestNutritionParsing(t *testing.T) {
+	html := `<table>
+		<tr><td>Brennwert</td><td>2720 kJ / 650 kcal</td></tr>
+		<tr><td>Eiweiß</td><td>32,5 g</td></tr>
+		<tr><td>Fett</td><td>12 g, davon gesättigte Fettsäuren 3 g</td></tr>
+	</table>`
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+
+	got := parseNutrition(doc.Selection, "tr", germanNutritionLabels)
+
+	t.Run("expect the number next to each label", func(t *testing.T) {
+		if got == nil || *got.Kcal != 650 || *got.Protein != 32.5 || *got.Fat != 12 {
+			t.Fatalf("expected 650 kcal, 32.5 g protein and 12 g fat but got %+v", got)
+		}
+	})
+
+	t.Run("expect values the page does not list to be missing", func(t *testing.T) {
+		if got.Carbohydrates != nil {
+			t.Fatalf("expected no carbohydrates but got %v", *got.Carbohydrates)
+		}
+	})
+
+	t.Run("expect no nutrition without values", func(t *testing.T) {
+		if nutrition := parseNutrition(doc.Selection, "td.missing", germanNutritionLabels); nutrition != nil {
+			t.Fatalf("expected no nutrition but got %+v", nutrition)
+		}
+	})
+
+	t.Run("expect missing values to be completed", func(t *testing.T) {
+		carbohydrates := 80.0
+		combined := got.complete(&Nutrition{Kcal: new(float64), Carbohydrates: &carbohydrates})
+		if *combined.Kcal != 650 || *combined.Carbohydrates != 80 {
+			t.Fatalf("expected the own kcal and the other carbohydrates but got %+v", combined)
+		}
+	})
+}
+
+func TestDetailLinkFollowing(t *testing.T) {
+	profile, _ := ActiveProfile(DefaultSourceName)
+	profile.Selectors.DetailLink = "a.details"
+	profile.Selectors.DetailNutrition = "li"
+	profile.Rules.Nutrition = germanNutritionLabels
+	source := cgmSource{profile: &profile}
+
+	var mutex sync.Mutex
+	running, maxRunning, requests := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		running++
+		requests++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Query().Get("artikel") == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			fmt.Fprintf(w, "<ul><li>%s kcal</li><li>Eiweiß: 20 g</li></ul>", r.URL.Query().Get("artikel"))
+		}
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	meals := make([]Meal, 0)
+	for i := 1; i <= 8; i++ {
+		meals = append(meals, Meal{Name: fmt.Sprint("Meal ", i), DetailUrl: fmt.Sprintf("?do=showartikel&artikel=%d", i*100)})
+	}
+	meals = append(meals, Meal{Name: "Same page", DetailUrl: "?do=showartikel&artikel=100"})
+	meals = append(meals, Meal{Name: "Broken page", DetailUrl: "?do=showartikel&artikel=broken"})
+
+	report := ParseReport{}
+	followDetailLinks(context.Background(), DefaultSourceName, source, server.URL+"/index.php", meals, &report)
+
+	t.Run("expect the nutrition of the detail pages", func(t *testing.T) {
+		if meals[1].Nutrition == nil || *meals[1].Nutrition.Kcal != 200 || *meals[1].Nutrition.Protein != 20 {
+			t.Fatalf("expected 200 kcal and 20 g protein but got %+v", meals[1].Nutrition)
+		}
+		if meals[1].DetailUrl != server.URL+"/index.php?do=showartikel&artikel=200" {
+			t.Fatalf("expected an absolute detail url but got %s", meals[1].DetailUrl)
+		}
+	})
+
+	t.Run("expect a detail page to be fetched once", func(t *testing.T) {
+		if requests != 9 || *meals[8].Nutrition.Kcal != 100 {
+			t.Fatalf("expected 9 requests and 100 kcal for the same page but got %d requests and %+v", requests, meals[8].Nutrition)
+		}
+	})
+
+	t.Run("expect the concurrency to be bounded", func(t *testing.T) {
+		if maxRunning > detailPageConcurrency() {
+			t.Fatalf("expected at most %d concurrent requests but got %d", detailPageConcurrency(), maxRunning)
+		}
+	})
+
+	t.Run("expect a broken detail page to be reported", func(t *testing.T) {
+		if meals[9].Nutrition != nil || len(report.Warnings) != 1 {
+			t.Fatalf("expected no nutrition and a warning but got %+v and %v", meals[9].Nutrition, report.Warnings)
+		}
+	})
+}
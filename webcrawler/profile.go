@@ -0,0 +1,222 @@
+package webcrawler
+
+import (
+	"fmt"
+	"github.com/Rate-My-Bistro/crawler/config"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Describes where the parts of a menu are found on the page of a source
+// Profiles are kept as versioned yaml files, so a redesign of the page needs no code change
+type SelectorProfile struct {
+	Source    string           `yaml:"source"`    // the name of the menu source the profile is for
+	Version   int              `yaml:"version"`   // profiles of the same source with a higher version replace lower ones
+	Selectors ProfileSelectors `yaml:"selectors"` // css selectors of the page nodes
+	Rules     ProfileRules     `yaml:"rules"`     // rules to extract values from the selected nodes
+	File      string           `yaml:"-"`         // the file the profile was loaded from
+}
+
+// Holds the css selectors of a profile
+// All selectors except the day and date selector are relative to the node of their parent
+type ProfileSelectors struct {
+	Day                      string `yaml:"day"`
+	Date                     string `yaml:"date"`
+	Meal                     string `yaml:"meal"`
+	MealName                 string `yaml:"mealName"`
+	MealPrice                string `yaml:"mealPrice"`
+	MandatorySupplement      string `yaml:"mandatorySupplement"`
+	OptionalSupplement       string `yaml:"optionalSupplement"`
+	OptionalSupplementColumn string `yaml:"optionalSupplementColumn"`
+	Label                    string `yaml:"label"`
+	Nutrition                string `yaml:"nutrition,omitempty"`       // optional, the nodes of a meal that list nutrition values
+	DetailLink               string `yaml:"detailLink,omitempty"`      // optional, the link of a meal to its detail page
+	DetailNutrition          string `yaml:"detailNutrition,omitempty"` // optional, the nodes of a detail page that list nutrition values
+	Image                    string `yaml:"image,omitempty"`           // optional, the img nodes of a meal
+}
+
+// Holds the extraction rules of a profile
+type ProfileRules struct {
+	DateLayout    string          `yaml:"dateLayout"`          // go time layout of the dates in the date headers
+	AllergenLabel string          `yaml:"allergenLabel"`       // the text in front of the allergen codes
+	AdditiveLabel string          `yaml:"additiveLabel"`       // the text in front of the additive codes
+	LowKcal       AttributeRule   `yaml:"lowKcal"`             // marks meals with few calories
+	Nutrition     NutritionLabels `yaml:"nutrition,omitempty"` // the labels of the nutrition values
+}
+
+// Matches nodes whose attribute contains a value
+type AttributeRule struct {
+	Attribute string `yaml:"attribute"`
+	Contains  string `yaml:"contains"`
+}
+
+// Holds the profile that is used for every source
+var activeProfiles = make(map[string]SelectorProfile)
+
+// Loads the selector profiles of all sources from the configured directory
+func init() {
+	cfg := config.Get()
+	profiles, err := LoadProfiles(cfg.ProfileDirectory)
+	if err != nil {
+		log.Fatal("Failed to load the selector profiles ", err)
+	}
+
+	activeProfiles, err = chooseProfiles(profiles, cfg.SourceProfiles)
+	if err != nil {
+		log.Fatal("Failed to choose the selector profiles ", err)
+	}
+}
+
+// Loads and validates a single selector profile from a yaml file
+func LoadProfile(file string) (SelectorProfile, error) {
+	var profile SelectorProfile
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return profile, err
+	}
+	if err := yaml.UnmarshalStrict(content, &profile); err != nil {
+		return profile, fmt.Errorf("reading the profile %s failed: %w", file, err)
+	}
+
+	profile.File = file
+	if err := profile.Validate(); err != nil {
+		return profile, fmt.Errorf("the profile %s is invalid: %w", file, err)
+	}
+	return profile, nil
+}
+
+// Loads all selector profiles of a directory, every yaml file holds one profile
+// Returns the profiles ordered by source and version
+func LoadProfiles(directory string) ([]SelectorProfile, error) {
+	files, err := filepath.Glob(filepath.Join(directory, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no profiles found in the directory '%s'", directory)
+	}
+
+	profiles := make([]SelectorProfile, 0, len(files))
+	for _, file := range files {
+		profile, err := LoadProfile(file)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Source != profiles[j].Source {
+			return profiles[i].Source < profiles[j].Source
+		}
+		return profiles[i].Version < profiles[j].Version
+	})
+	return profiles, nil
+}
+
+// Chooses the profile of every source, which is the highest version unless a version is pinned
+// Versions are pinned as 'source=version' pairs
+// Returns the chosen profiles by their source or an error if a pinned version does not exist
+func chooseProfiles(profiles []SelectorProfile, pinnedVersions []string) (map[string]SelectorProfile, error) {
+	pins := make(map[string]int)
+	for _, pinnedVersion := range pinnedVersions {
+		pair := strings.SplitN(pinnedVersion, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("expected a profile pin like 'cgm=1' but got '%s'", pinnedVersion)
+		}
+		version, err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in the profile pin '%s'", pinnedVersion)
+		}
+		pins[strings.TrimSpace(pair[0])] = version
+	}
+
+	chosen := make(map[string]SelectorProfile)
+	for _, profile := range profiles {
+		if version, pinned := pins[profile.Source]; !pinned || version == profile.Version {
+			chosen[profile.Source] = profile
+		}
+	}
+
+	for source, version := range pins {
+		if chosen[source].Version != version {
+			return nil, fmt.Errorf("no profile with version %d found for the source %s", version, source)
+		}
+	}
+	return chosen, nil
+}
+
+// Returns the selector profile that is used for the named source
+func ActiveProfile(sourceName string) (SelectorProfile, error) {
+	profile, found := activeProfiles[sourceName]
+	if !found {
+		return profile, fmt.Errorf("no selector profile loaded for the source %s", sourceName)
+	}
+	return profile, nil
+}
+
+// Checks that all selectors of the profile are valid css selectors and all rules are present
+func (profile SelectorProfile) Validate() error {
+	if profile.Source == "" {
+		return fmt.Errorf("the source is missing")
+	}
+	if profile.Version < 1 {
+		return fmt.Errorf("the version must be at least 1")
+	}
+
+	selectors := []struct{ name, selector string }{
+		{"day", profile.Selectors.Day},
+		{"date", profile.Selectors.Date},
+		{"meal", profile.Selectors.Meal},
+		{"mealName", profile.Selectors.MealName},
+		{"mealPrice", profile.Selectors.MealPrice},
+		{"mandatorySupplement", profile.Selectors.MandatorySupplement},
+		{"optionalSupplement", profile.Selectors.OptionalSupplement},
+		{"optionalSupplementColumn", profile.Selectors.OptionalSupplementColumn},
+		{"label", profile.Selectors.Label},
+	}
+	for _, s := range selectors {
+		if s.selector == "" {
+			return fmt.Errorf("the selector '%s' is missing", s.name)
+		}
+		if _, err := cascadia.Compile(s.selector); err != nil {
+			return fmt.Errorf("the selector '%s' is invalid: %w", s.name, err)
+		}
+	}
+
+	optionalSelectors := []struct{ name, selector string }{
+		{"nutrition", profile.Selectors.Nutrition},
+		{"detailLink", profile.Selectors.DetailLink},
+		{"detailNutrition", profile.Selectors.DetailNutrition},
+		{"image", profile.Selectors.Image},
+	}
+	for _, s := range optionalSelectors {
+		if s.selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(s.selector); err != nil {
+			return fmt.Errorf("the selector '%s' is invalid: %w", s.name, err)
+		}
+	}
+
+	if profile.Rules.DateLayout == "" {
+		return fmt.Errorf("the date layout is missing")
+	}
+	if profile.Rules.AllergenLabel == "" || profile.Rules.AdditiveLabel == "" {
+		return fmt.Errorf("the allergen or additive label is missing")
+	}
+	if profile.Rules.LowKcal.Attribute == "" || profile.Rules.LowKcal.Contains == "" {
+		return fmt.Errorf("the low kcal rule is incomplete")
+	}
+	if (profile.Selectors.Nutrition != "" || profile.Selectors.DetailNutrition != "") && profile.Rules.Nutrition == (NutritionLabels{}) {
+		return fmt.Errorf("the nutrition labels are missing")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package webcrawler
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSelectorProfiles(t *testing.T) {
+	profiles, err := LoadProfiles("../profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("expect the shipped profiles to be valid", func(t *testing.T) {
+		if len(profiles) == 0 || profiles[0].Source != DefaultSourceName {
+			t.Fatalf("expected a profile of the source %s but got %+v", DefaultSourceName, profiles)
+		}
+	})
+
+	t.Run("expect the highest version to be chosen", func(t *testing.T) {
+		newer := profiles[0]
+		newer.Version = profiles[0].Version + 1
+		chosen, err := chooseProfiles([]SelectorProfile{profiles[0], newer}, nil)
+		if err != nil || chosen[DefaultSourceName].Version != newer.Version {
+			t.Fatalf("expected version %d to be chosen but got %+v: %v", newer.Version, chosen, err)
+		}
+	})
+
+	t.Run("expect a pinned version to be chosen", func(t *testing.T) {
+		newer := profiles[0]
+		newer.Version = profiles[0].Version + 1
+		chosen, err := chooseProfiles([]SelectorProfile{profiles[0], newer}, []string{"cgm=1"})
+		if err != nil || chosen[DefaultSourceName].Version != 1 {
+			t.Fatalf("expected version 1 to be chosen but got %+v: %v", chosen, err)
+		}
+	})
+
+	t.Run("expect an error for a pinned version that does not exist", func(t *testing.T) {
+		if _, err := chooseProfiles(profiles, []string{"cgm=99"}); err == nil {
+			t.Fatalf("expected an error for a missing version")
+		}
+	})
+
+	t.Run("expect an error for an invalid selector", func(t *testing.T) {
+		invalid := profiles[0]
+		invalid.Selectors.Meal = "div[style="
+		if err := invalid.Validate(); err == nil {
+			t.Fatalf("expected an error for an invalid selector")
+		}
+	})
+
+	t.Run("expect a redesigned page to be parsed with an adjusted profile", func(t *testing.T) {
+		page, _ := ioutil.ReadFile("bistro.html")
+		redesigned := strings.Replace(string(page), `class="table-col" id="day"`, `class="table-col weekday"`, -1)
+
+		profile := profiles[0]
+		profile.Selectors.Day = "div.weekday"
+		source := cgmSource{}.WithProfile(profile)
+		meals, report, err := source.ParseWeek(strings.NewReader(redesigned))
+		if err != nil || len(meals) != 21 || len(report.SkippedNodes) != 0 {
+			t.Fatalf("expected 21 meals but got %d meals and %+v: %v", len(meals), report, err)
+		}
+	})
+}
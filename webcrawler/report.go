@@ -0,0 +1,83 @@
+package webcrawler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Collects everything that did not go as expected while parsing a page
+// Parsing continues after skipped nodes and warnings, only a failed selector aborts it
+type ParseReport struct {
+	SkippedNodes   []SkippedNode     `json:"skippedNodes"`             // nodes that could not be parsed and were left out
+	Warnings       []string          `json:"warnings"`                 // oddities that did not prevent parsing a node
+	FailedSelector string            `json:"failedSelector,omitempty"` // the selector that aborted the parsing
+	SnapshotIds    []string          `json:"snapshotIds,omitempty"`    // the archived pages that were parsed
+	DetailPageIds  []string          `json:"detailPageIds,omitempty"`  // the archived detail pages that were parsed
+	Days           []Day             `json:"days,omitempty"`           // the state of every parsed day
+	Fingerprints   []PageFingerprint `json:"fingerprints,omitempty"`   // the layout skeleton of every parsed page
+	UnchangedWeeks []string          `json:"unchangedWeeks,omitempty"` // the weeks whose page was not modified since the last crawl
+}
+
+// Represents a html node that was left out because it could not be parsed
+type SkippedNode struct {
+	Selector string `json:"selector"` // the selector that matched the node
+	Index    int    `json:"index"`    // the position of the node within the selection
+	Reason   string `json:"reason"`   // why the node was skipped
+}
+
+// Represents a parse step that failed because the page does not look as expected
+type ParseError struct {
+	Selector string // the selector that did not match the expected content
+	Message  string // what was expected
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("parsing selector '%s' failed: %s", err.Selector, err.Message)
+}
+
+// Creates a new parse error for the specified selector
+func newParseError(selector string, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Selector: selector,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Records a node that was left out because of the specified error
+func (report *ParseReport) skipNode(selector string, index int, err error) {
+	report.SkippedNodes = append(report.SkippedNodes, SkippedNode{
+		Selector: selector,
+		Index:    index,
+		Reason:   err.Error(),
+	})
+}
+
+// Records a warning that did not prevent parsing
+func (report *ParseReport) addWarning(format string, args ...interface{}) {
+	report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Adds the skipped nodes, warnings, snapshots, detail pages, days, fingerprints and unchanged weeks of another report to this report
+// The failed selector of the other report is only taken if this report has none yet
+func (report *ParseReport) merge(other ParseReport) {
+	report.SkippedNodes = append(report.SkippedNodes, other.SkippedNodes...)
+	report.Warnings = append(report.Warnings, other.Warnings...)
+	report.SnapshotIds = append(report.SnapshotIds, other.SnapshotIds...)
+	report.DetailPageIds = append(report.DetailPageIds, other.DetailPageIds...)
+	report.Days = append(report.Days, other.Days...)
+	report.Fingerprints = append(report.Fingerprints, other.Fingerprints...)
+	report.UnchangedWeeks = append(report.UnchangedWeeks, other.UnchangedWeeks...)
+	if report.FailedSelector == "" {
+		report.FailedSelector = other.FailedSelector
+	}
+}
+
+// Records the selector of an error that aborted the parsing
+// Returns the passed error to allow a direct return
+func (report *ParseReport) fail(err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		report.FailedSelector = parseErr.Selector
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package webcrawler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holds the robots.txt rules of a host that apply to our user agent
+type robotsRules struct {
+	allows     []string      // path patterns that may be requested
+	disallows  []string      // path patterns that must not be requested
+	crawlDelay time.Duration // the time to wait between two requests, zero if the host asks for none
+}
+
+// Represents a request that the robots.txt of the host does not allow
+type RobotsError struct {
+	Url string
+}
+
+func (err *RobotsError) Error() string {
+	return fmt.Sprintf("the robots.txt of the host disallows requesting %s", err.Url)
+}
+
+// Parses the content of a robots.txt file
+// Only the group of the user agent applies, hosts without such a group apply the group of all agents '*'
+// Returns the rules of the applying group, which allow everything if no group applies
+func parseRobots(content []byte, userAgent string) robotsRules {
+	// the product token is the user agent without its version, e.g. RateMyBistroCrawler
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+
+	var agentRules, wildcardRules *robotsRules
+	var group []string
+	var rules *robotsRules
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		pair := strings.SplitN(line, ":", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(pair[0]))
+		value := strings.TrimSpace(pair[1])
+
+		if field == "user-agent" {
+			// consecutive user agent lines share the rules that follow them
+			if inRules {
+				group, inRules = nil, false
+			}
+			group = append(group, strings.ToLower(value))
+			continue
+		}
+
+		if !inRules {
+			inRules = true
+			rules = &robotsRules{}
+			for _, agent := range group {
+				if agent == "*" && wildcardRules == nil {
+					wildcardRules = rules
+				} else if agent != "*" && token != "" && strings.Contains(token, agent) && agentRules == nil {
+					agentRules = rules
+				}
+			}
+		}
+
+		switch field {
+		case "allow":
+			if value != "" {
+				rules.allows = append(rules.allows, value)
+			}
+		case "disallow":
+			if value != "" {
+				rules.disallows = append(rules.disallows, value)
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	if agentRules != nil {
+		return *agentRules
+	}
+	if wildcardRules != nil {
+		return *wildcardRules
+	}
+	return robotsRules{}
+}
+
+// Tells if the rules allow requesting the path, which includes the query of the url
+// The longest matching pattern wins, an allow wins over a disallow of the same length
+func (rules robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	longestAllow := longestMatch(rules.allows, path)
+	longestDisallow := longestMatch(rules.disallows, path)
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+// Returns the length of the longest pattern that matches the path or -1 if none matches
+func longestMatch(patterns []string, path string) int {
+	longest := -1
+	for _, pattern := range patterns {
+		if len(pattern) > longest && matchesRobotsPattern(pattern, path) {
+			longest = len(pattern)
+		}
+	}
+	return longest
+}
+
+// Tells if a robots.txt path pattern matches the path
+// Patterns match path prefixes, a '*' matches any characters and a trailing '$' anchors the pattern at the end
+func matchesRobotsPattern(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	expression := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+	if anchored {
+		expression += "$"
+	}
+
+	matcher, err := regexp.Compile(expression)
+	return err == nil && matcher.MatchString(path)
+}
@@ -0,0 +1,63 @@
+package webcrawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRobotsParsing(t *testing.T) {
+	content := []byte(`# robots.txt of the bistro
+User-agent: *
+Disallow: /admin
+Crawl-delay: 2
+
+User-agent: SomeBot
+User-agent: RateMyBistroCrawler
+Disallow: /index.php?day=*&year=2019
+Disallow: /*.pdf$
+Allow: /index.php?day=1&month=6&year=2019
+Crawl-delay: 0.5
+`)
+	rules := parseRobots(content, "RateMyBistroCrawler/1.0")
+
+	t.Run("expect the group of the user agent to apply", func(t *testing.T) {
+		if !rules.allowed("/admin") || rules.crawlDelay != 500*time.Millisecond {
+			t.Fatalf("expected the rules of our user agent but got %+v", rules)
+		}
+	})
+
+	t.Run("expect wildcards to match", func(t *testing.T) {
+		if rules.allowed("/index.php?day=8&month=6&year=2019") {
+			t.Fatalf("expected a page of 2019 to be disallowed")
+		}
+		if !rules.allowed("/index.php?day=8&month=6&year=2020") {
+			t.Fatalf("expected a page of 2020 to be allowed")
+		}
+	})
+
+	t.Run("expect the end anchor to match only at the end", func(t *testing.T) {
+		if rules.allowed("/menu.pdf") || !rules.allowed("/menu.pdf.html") {
+			t.Fatalf("expected only paths ending with .pdf to be disallowed")
+		}
+	})
+
+	t.Run("expect the longest match to win", func(t *testing.T) {
+		if !rules.allowed("/index.php?day=1&month=6&year=2019") {
+			t.Fatalf("expected the longer allow to win over the disallow")
+		}
+	})
+
+	t.Run("expect the group of all agents for other user agents", func(t *testing.T) {
+		other := parseRobots(content, "OtherCrawler/2.0")
+		if other.allowed("/admin/jobs") || other.crawlDelay != 2*time.Second {
+			t.Fatalf("expected the rules of all agents but got %+v", other)
+		}
+	})
+
+	t.Run("expect everything to be allowed without a matching group", func(t *testing.T) {
+		none := parseRobots([]byte("User-agent: SomeBot\nDisallow: /\n"), "RateMyBistroCrawler/1.0")
+		if !none.allowed("/") {
+			t.Fatalf("expected everything to be allowed but got %+v", none)
+		}
+	})
+}
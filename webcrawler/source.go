@@ -0,0 +1,74 @@
+package webcrawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+// Represents a website that publishes the menu of a canteen week by week
+// Every supported page layout comes with its own implementation
+type MenuSource interface {
+	// Fetches the page of the week that contains the specified date
+	// The date must have the format 'yyyy-mm-dd', leave it blank to fetch the current week
+	// Fetching must stop once the context is done
+	FetchWeek(ctx context.Context, location string, date string) (io.Reader, error)
+
+	// Parses a fetched week page
+	// Returns all meals found on the page and a report about the nodes that could not be parsed
+	ParseWeek(reader io.Reader) ([]Meal, ParseReport, error)
+}
+
+// Represents a menu source whose page layout is described by a selector profile
+type ProfiledSource interface {
+	MenuSource
+
+	// Returns the same source that parses pages with the specified profile instead of the active one
+	WithProfile(profile SelectorProfile) MenuSource
+}
+
+// Represents a menu source whose meals link to detail pages with further information
+type DetailSource interface {
+	MenuSource
+
+	// Parses the nutrition of a meal from its fetched detail page
+	// Returns nil if the page lists no nutrition
+	ParseDetail(reader io.Reader) (*Nutrition, error)
+}
+
+// The name of the source that is used when no source is specified
+const DefaultSourceName = "cgm"
+
+// Holds all registered menu sources by their name
+var sources = make(map[string]MenuSource)
+
+// Registers a menu source under the specified name
+// Registering the same name twice is a programming error and stops the application
+func RegisterSource(name string, source MenuSource) {
+	if _, exists := sources[name]; exists {
+		log.Fatalf("A menu source with the name %s is already registered", name)
+	}
+	sources[name] = source
+}
+
+// Retrieves a registered menu source by its name
+// Returns an error if no source is registered under that name
+func GetSource(name string) (MenuSource, error) {
+	source, exists := sources[name]
+	if !exists {
+		return nil, fmt.Errorf("no menu source registered with the name %s", name)
+	}
+	return source, nil
+}
+
+// Returns the names of all registered menu sources in alphabetical order
+func SourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
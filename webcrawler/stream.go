@@ -0,0 +1,95 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Holds the meals and the report of a single crawled week
+type WeekBatch struct {
+	WeekDate string      // the date the week was crawled for, blank for the current week
+	Week     int         // the position of the week within the crawled range, starting at 1
+	Weeks    int         // the number of weeks of the crawled range
+	Meals    []Meal      // the meals of the week, meals outside the crawled range are dropped
+	Report   ParseReport // the report of the week, days outside the crawled range are dropped
+}
+
+// Receives every crawled week as soon as it is parsed
+// The crawl waits until the handler returns, so a slow handler slows the crawl down instead of piling up weeks
+// Returning an error stops the crawl, which then fails with the error
+type MealHandler func(ctx context.Context, batch WeekBatch) error
+
+// Crawls the week that contains the specified date from the named menu source and hands it to the handler
+// Leave the date blank to crawl the current week
+// returns the report of the week or ErrNotModified without calling the handler if the page was not modified
+func StreamSource(ctx context.Context, sourceName string, location string, date string, handler MealHandler) (ParseReport, error) {
+	meals, report, err := CrawlSource(ctx, sourceName, location, date)
+	if err != nil {
+		return report, err
+	}
+
+	batch := WeekBatch{WeekDate: date, Week: 1, Weeks: 1, Meals: meals, Report: report}
+	if err := handler(ctx, batch); err != nil {
+		return report, fmt.Errorf("handling the week of %s failed: %w", describeWeek(date), err)
+	}
+	return report, nil
+}
+
+// Crawls all weeks between two dates from the named menu source and hands them to the handler one by one
+// Both dates are inclusive and must have the format 'yyyy-mm-dd'
+// Weeks whose page was not modified since the last crawl are not handed over but listed in the report
+// returns the combined report of all crawled weeks or ErrNotModified if no page of the range was modified
+// Weeks that were handed over before an error stay handed over
+func StreamSourceRange(ctx context.Context, sourceName string, location string, from string, to string, handler MealHandler) (report ParseReport, err error) {
+	weeks, err := weekDates(from, to)
+	if err != nil {
+		return report, err
+	}
+
+	for i, weekDate := range weeks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		weekMeals, weekReport, err := CrawlSource(ctx, sourceName, location, weekDate)
+		if errors.Is(err, ErrNotModified) {
+			report.UnchangedWeeks = append(report.UnchangedWeeks, weekDate)
+			continue
+		}
+		weekReport.Days = daysWithin(weekReport.Days, from, to)
+		report.merge(weekReport)
+		if err != nil {
+			return report, fmt.Errorf("crawling the week of %s failed: %w", weekDate, err)
+		}
+
+		batch := WeekBatch{WeekDate: weekDate, Week: i + 1, Weeks: len(weeks), Meals: mealsWithin(weekMeals, from, to), Report: weekReport}
+		if err := handler(ctx, batch); err != nil {
+			return report, fmt.Errorf("handling the week of %s failed: %w", weekDate, err)
+		}
+	}
+
+	if len(report.UnchangedWeeks) == len(weeks) {
+		return report, ErrNotModified
+	}
+	return report, nil
+}
+
+// Filters the meals that are offered between the first and the last date, both inclusive
+func mealsWithin(meals []Meal, from string, to string) []Meal {
+	mealsInRange := make([]Meal, 0, len(meals))
+	for _, meal := range meals {
+		if meal.Date >= from && meal.Date <= to {
+			mealsInRange = append(mealsInRange, meal)
+		}
+	}
+	return mealsInRange
+}
+
+// Describes the week of a date for error messages
+func describeWeek(date string) string {
+	if date == "" {
+		return "the current week"
+	}
+	return date
+}
@@ -0,0 +1,69 @@
+package webcrawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamRange(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeFile(w, r, "bistro.html")
+	}))
+	defer server.Close()
+
+	t.Run("expect every week to be handed over in order", func(t *testing.T) {
+		ForgetPageValidators()
+		batches := make([]WeekBatch, 0)
+		_, err := StreamSourceRange(context.Background(), DefaultSourceName, server.URL, "2020-06-09", "2020-06-15", func(ctx context.Context, batch WeekBatch) error {
+			batches = append(batches, batch)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batches) != 2 || batches[0].Week != 1 || batches[1].Week != 2 || batches[1].Weeks != 2 {
+			t.Fatalf("expected the weeks 1 and 2 of 2 but got %+v", batches)
+		}
+		if len(batches[0].Meals) != 16 || batches[0].WeekDate != "2020-06-09" {
+			t.Fatalf("expected 16 meals of the week of 2020-06-09 but got %d meals of %s", len(batches[0].Meals), batches[0].WeekDate)
+		}
+	})
+
+	t.Run("expect an error of the handler to stop the crawling", func(t *testing.T) {
+		ForgetPageValidators()
+		requests = 0
+		handlerErr := errors.New("database unavailable")
+		handled := 0
+		_, err := StreamSourceRange(context.Background(), DefaultSourceName, server.URL, "2020-06-09", "2020-06-15", func(ctx context.Context, batch WeekBatch) error {
+			handled++
+			return handlerErr
+		})
+		if !errors.Is(err, handlerErr) {
+			t.Fatalf("expected the error of the handler but got %v", err)
+		}
+		if handled != 1 || requests != 1 {
+			t.Fatalf("expected 1 handled week after 1 request but got %d after %d requests", handled, requests)
+		}
+	})
+
+	t.Run("expect unchanged weeks not to be handed over", func(t *testing.T) {
+		ForgetPageValidators()
+		handled := 0
+		StreamSourceRange(context.Background(), DefaultSourceName, server.URL, "2020-06-09", "2020-06-09", func(ctx context.Context, batch WeekBatch) error {
+			handled++
+			return nil
+		})
+		_, err := StreamSource(context.Background(), DefaultSourceName, server.URL, "2020-06-09", func(ctx context.Context, batch WeekBatch) error {
+			handled++
+			return nil
+		})
+		if !errors.Is(err, ErrNotModified) || handled != 1 {
+			t.Fatalf("expected the unchanged week to be handed over once but got %v after %d weeks", err, handled)
+		}
+	})
+}
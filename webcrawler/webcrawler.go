@@ -1,179 +1,160 @@
 /*
-Package crawler implements a simple parser that reads the cgm bistro website
-and transforms them into manageable structs.
+Package crawler implements a simple parser that reads canteen menu websites
+(e.g. the cgm bistro) and transforms them into manageable structs.
+Every supported website layout is provided as a MenuSource.
 */
 package webcrawler
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"io"
-	"log"
-	"net/http"
+	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
-	"time"
 )
 
 // Represents a meal
 type Meal struct {
 	// The Id is the identifier of each meal
-	// it is composed like this: sha1( date + name )
+	// it is composed like this: sha1( source + "-" + date + normalized name )
+	// edited names keep the id of the meal they were matched with
 	Id                   string       `json:"_key,omitempty"`
+	Source               string       `json:"source"` // the menu source the meal was crawled from
 	Date                 string       `json:"date"`
 	Name                 string       `json:"name"`
-	Price                float64      `json:"price"`
+	Price                Money        `json:"price" swaggertype:"number"` // euros as decimal number, e.g. 3.97, other currencies as object, e.g. {"amount": 4.50, "currency": "CHF"}
 	LowKcal              bool         `json:"lowKcal"`
 	MandatorySupplements []Supplement `json:"mandatorySupplements"`
 	OptionalSupplements  []Supplement `json:"optionalSupplements"`
+	Allergens            []string     `json:"allergens"`           // canonical allergens, e.g. GLUTEN or MILK
+	Additives            []string     `json:"additives"`           // canonical additives, e.g. PRESERVATIVE
+	Nutrition            *Nutrition   `json:"nutrition,omitempty"` // calories and nutrients, if the source lists them
+	DetailUrl            string       `json:"detailUrl,omitempty"` // the page with details about the meal, if the source links one
+	Images               []MealImage  `json:"images,omitempty"`    // pictures of the meal, if the source shows them
+	DietTags             []DietTag    `json:"dietTags,omitempty"`  // the classified or overridden diets, e.g. VEGETARIAN
 }
 
 //  Represents a supplement of an meal
 type Supplement struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name  string `json:"name"`
+	Price Money  `json:"price" swaggertype:"number"` // euros as decimal number, other currencies as object with amount and currency
 }
 
 // Crawls the content of the cgm bistro website for the current week
-// returns a slice of meals
-func CrawlCurrentWeek(bistroLocation string) (mealDates []Meal, err error) {
-	reader, err := createBistroReader(bistroLocation)
-
-	doc, err := requestWebsiteDocument(reader)
-
-	parsedDates := parseDates(doc)
-	mealDates = parseMealsForAllDays(doc, parsedDates)
-
-	return mealDates, err
+// returns a slice of meals and a report about the parsing
+func CrawlCurrentWeek(ctx context.Context, bistroLocation string) (mealDates []Meal, report ParseReport, err error) {
+	return CrawlSource(ctx, DefaultSourceName, bistroLocation, "")
 }
 
 // Receives a reader that provides the content of a bistro website for the specified date
 // The date must have the format 'yyyy-mm-dd' example: '2020-12-31'
-// returns a slice of meals for the week
-func CrawlAtDate(bistroLocation string, date string) (mealDates []Meal, err error) {
-	if !strings.HasPrefix(bistroLocation, "http") {
-		err := fmt.Errorf("specific dates cannot parsed from an offline location only urls are allowed")
-		return nil, err
+// returns a slice of meals for the week and a report about the parsing
+func CrawlAtDate(ctx context.Context, bistroLocation string, date string) (mealDates []Meal, report ParseReport, err error) {
+	return CrawlSource(ctx, DefaultSourceName, bistroLocation, date)
+}
+
+// Crawls the week that contains the specified date from the named menu source
+// Leave the date blank to crawl the current week
+// The fetching is aborted as soon as the context is cancelled or its deadline is exceeded
+// returns a slice of meals for the week and a report about the parsing
+// or ErrNotModified without parsing if the page was not modified since the last crawl
+// Meals of sources with detail pages get the nutrition of their detail page, images of meals are downloaded
+// and the diet of every meal is classified
+func CrawlSource(ctx context.Context, sourceName string, location string, date string) (mealDates []Meal, report ParseReport, err error) {
+	source, err := GetSource(sourceName)
+	if err != nil {
+		return nil, report, err
 	}
 
-	bistroLocation = buildDatedBistroLocation(bistroLocation, date)
-
-	reader, err := createBistroReader(bistroLocation)
+	reader, err := source.FetchWeek(ctx, location, date)
 	if err != nil {
-		return nil, err
+		return nil, report, err
 	}
 
-	doc, err := requestWebsiteDocument(reader)
+	page, err := readPage(reader)
 	if err != nil {
-		return nil, err
+		return nil, report, err
 	}
 
-	dates := parseDates(doc)
-	mealDates = parseMealsForAllDays(doc, dates)
+	snapshotId, archiveErr := archivePage(sourceName, page)
 
-	return mealDates, nil
-}
+	mealDates, report, err = source.ParseWeek(bytes.NewReader(page))
+	if detailSource, ok := source.(DetailSource); ok && err == nil {
+		followDetailLinks(ctx, sourceName, detailSource, location, mealDates, &report)
+	}
+	if err == nil {
+		downloadImages(ctx, location, mealDates, &report)
+		classifyMeals(mealDates)
+	}
+	if archiveErr != nil {
+		report.addWarning("archiving the fetched page failed: %s", archiveErr)
+	} else if snapshotId != "" {
+		report.SnapshotIds = append(report.SnapshotIds, snapshotId)
+	}
 
-func buildDatedBistroLocation(location string, date string) string {
-	split := strings.Split(date, "-")
-	year := split[0]
-	month := split[1]
-	day := split[2]
+	return mealDates, report, err
+}
 
-	if !strings.HasSuffix(location, "index.php") {
-		location = strings.Replace(location+"/index.php", "//", "/", -1)
-		location = strings.Replace(location, ":/", "://", 1)
+// Reads a fetched page completely
+// The reader is closed afterwards if it holds a resource like a file
+func readPage(reader io.Reader) ([]byte, error) {
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
 	}
+	return ioutil.ReadAll(reader)
+}
 
-	location = location + fmt.Sprintf("?day=%s&month=%s&year=%s", day, month, year)
+// Stores a fetched page in the snapshot archive
+// Returns the id of the snapshot or a blank id if archiving is disabled
+func archivePage(sourceName string, page []byte) (string, error) {
+	if defaultArchive == nil {
+		return "", nil
+	}
+	return defaultArchive.Store(sourceName, page)
+}
 
-	return location
+// Stores a fetched detail page in the snapshot archive
+// Returns the id of the snapshot or a blank id if archiving is disabled
+func archiveDetailPage(sourceName string, page []byte) (string, error) {
+	if defaultArchive == nil {
+		return "", nil
+	}
+	return defaultArchive.StoreDetail(sourceName, page)
 }
 
 // creates an reader object based on the provided bistroUrl
-func createBistroReader(bistroUrl string) (documentReader io.Reader, err error) {
+// the context limits the request of urls, files are read without it
+func createBistroReader(ctx context.Context, bistroUrl string) (documentReader io.Reader, err error) {
 	if strings.HasPrefix(bistroUrl, "file://") {
 		bistroUrl := strings.Replace(bistroUrl, "file://", "", -1)
-		documentReader = readFile(bistroUrl)
+		documentReader, err = readFile(bistroUrl)
 	} else if strings.HasPrefix(bistroUrl, "/") {
-		documentReader = readFile(bistroUrl)
+		documentReader, err = readFile(bistroUrl)
 	} else {
-		url, getErr := http.Get(bistroUrl)
-		if getErr != nil {
-			return nil, getErr
+		body, fetchErr := defaultFetcher.Fetch(ctx, bistroUrl)
+		if fetchErr != nil {
+			return nil, fetchErr
 		}
-		documentReader = url.Body
+		documentReader = bytes.NewReader(body)
 	}
 
 	return documentReader, err
 }
 
 // retrieves a file handle from the specified file path
-func readFile(filePath string) *os.File {
+func readFile(filePath string) (*os.File, error) {
 	bistroPageReader, err := os.Open(filePath)
 
 	if err != nil {
-		log.Fatal("Opening the following file failed: "+
-			filePath, err)
+		return nil, fmt.Errorf("opening the file %s failed: %w", filePath, err)
 	}
 
-	return bistroPageReader
-}
-
-// Parses all meals found in the provided html document
-// Receives a document that holds the bistro website
-// Returns a map of meals
-func parseMealsForAllDays(doc *goquery.Document, parsedDates []string) []Meal {
-	meals := make([]Meal, 0)
-
-	doc.Find("div#day").Each(func(i int, daySelection *goquery.Selection) {
-		date := parsedDates[i]
-		parsedMeals := parseMealsForDay(daySelection)
-
-		for _, meal := range parsedMeals {
-			meal.Date = date
-			meal.Id = toSha1(meal.Date + meal.Name)
-			meals = append(meals, meal)
-		}
-	})
-
-	return meals
-}
-
-// parses all meals for a given day
-// receives a selector that holds the meal data of a single day
-// returns a set of meals
-func parseMealsForDay(daySelection *goquery.Selection) []Meal {
-	var meals []Meal
-
-	daySelection.Find("div#meal").Each(func(i int, mealSelection *goquery.Selection) {
-		meal := Meal{}
-		meal.Name = mealSelection.Find("p.menuName").Text()
-		meal.Price = convertToPrice(mealSelection.Find("p.preis > b").Text())
-		meal.LowKcal = containsAttributeValue(mealSelection, "style", "background-color:greenyellow")
-		meal.MandatorySupplements = parseMandatorySupplements(mealSelection)
-		meal.OptionalSupplements = parseOptionalSupplements(mealSelection)
-
-		// filters out days without meals (e.g. holidays)
-		if meal.Price > 0 {
-			meals = append(meals, meal)
-		}
-	})
-	return meals
-}
-
-func parseMandatorySupplements(mealSelection *goquery.Selection) (mandatorySupplements []Supplement) {
-	mandatorySupplementName := strings.TrimSpace(mealSelection.Find("p.beschreibung").Text())
-	if mandatorySupplementName != "" {
-		mandatorySupplements = append(mandatorySupplements, Supplement{
-			Name:  mandatorySupplementName,
-			Price: 0,
-		})
-	}
-	return mandatorySupplements
+	return bistroPageReader, nil
 }
 
 // Checks if a html selection tag contains the specified attribute value
@@ -187,51 +168,6 @@ func containsAttributeValue(selection *goquery.Selection, attributeName string,
 	return false
 }
 
-// Parses all dates of the week
-// Receives a queryable html document
-// Returns a set of string dates in the format: yyyy-mm-dd
-func parseDates(doc *goquery.Document) []string {
-	parsedDates := make([]string, 0)
-	// Parse date for this day
-	doc.Find("div.table-col-header b").Each(func(i int, dateSelection *goquery.Selection) {
-		dateString := dateSelection.Nodes[0].LastChild.Data
-		parsedDate, _ := time.Parse("2.1.2006", dateString)
-		dateString = parsedDate.Format("2006-01-02")
-		parsedDates = append(parsedDates, dateString)
-	})
-	return parsedDates
-}
-
-// Parses supplements of a given meal selection
-// Receives a queryable meal selection
-// Returns a set of supplements or an empty slice if no supplements found for a meal
-func parseOptionalSupplements(mealSelection *goquery.Selection) (optionalSupplements []Supplement) {
-	mealSelection.Find("div[style='padding-left:10px']").Each(func(i int, supplementSelection *goquery.Selection) {
-		optionalSupplement := Supplement{}
-
-		nameString := strings.TrimSpace(supplementSelection.Find("div").Nodes[0].FirstChild.Data)
-		priceString := supplementSelection.Find("div").Nodes[1].FirstChild.Data
-
-		optionalSupplement.Name = nameString
-		optionalSupplement.Price = convertToPrice(priceString)
-
-		optionalSupplements = append(optionalSupplements, optionalSupplement)
-	})
-
-	return optionalSupplements
-}
-
-// Converts a price string that comes from the bistro page into a float
-// Receives a price as string
-// Returns a price as float
-func convertToPrice(priceString string) float64 {
-	priceString = strings.Replace(priceString, ",", ".", -1)
-	priceString = strings.Replace(priceString, "€", "", -1)
-	priceString = strings.TrimSpace(priceString)
-	price, _ := strconv.ParseFloat(priceString, 64)
-	return price
-}
-
 // Requests a website content from a given reader
 // Receives a reader interface
 // Returns queryable html document
@@ -1,13 +1,23 @@
 package webcrawler
 
 import (
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestBistroWebCrawling(t *testing.T) {
 
-	got, _ := CrawlCurrentWeek("file://bistro.html")
+	got, report, err := CrawlCurrentWeek(context.Background(), "file://bistro.html")
+
+	t.Run("expect a clean parse report", func(t *testing.T) {
+		if err != nil || len(report.SkippedNodes) != 0 || len(report.Warnings) != 0 {
+			t.Fatalf("expected no errors, skipped nodes or warnings but got %s and %+v", err, report)
+		}
+	})
 
 	t.Run("expect the correct size", func(t *testing.T) {
 		if len(got) != 21 {
@@ -45,6 +55,27 @@ func TestBistroWebCrawling(t *testing.T) {
 		}
 	})
 
+	t.Run("expect the allergens of the first meal", func(t *testing.T) {
+		want := []string{AllergenGluten, AllergenEggs, AllergenMilk, AllergenCelery}
+		if !reflect.DeepEqual(got[0].Allergens, want) {
+			t.Fatalf("expected the allergens %v but got %v", want, got[0].Allergens)
+		}
+	})
+
+	t.Run("expect the additives of the first meal", func(t *testing.T) {
+		want := []string{AdditivePreservative, AdditiveSweetener}
+		if !reflect.DeepEqual(got[0].Additives, want) {
+			t.Fatalf("expected the additives %v but got %v", want, got[0].Additives)
+		}
+	})
+
+	t.Run("expect no allergens for a meal with an empty legend", func(t *testing.T) {
+		pizzaHawaii := got[len(got)-3]
+		if pizzaHawaii.Name != "Pizza Hawaii" || len(pizzaHawaii.Allergens) != 0 || len(pizzaHawaii.Additives) != 0 {
+			t.Fatalf("expected Pizza Hawaii without allergens and additives but got %+v", pizzaHawaii)
+		}
+	})
+
 	t.Run("expect correct low kcal parsing", func(t *testing.T) {
 		if got[0].LowKcal != false {
 			t.Fatalf("expected the first meal of the week to be NOT low kcal")
@@ -55,6 +86,124 @@ func TestBistroWebCrawling(t *testing.T) {
 	})
 }
 
+func TestLabelCodeParsing(t *testing.T) {
+	html := `<div id="meal"><div><div>Allergen: A, X, N</div></div><div><div>Zusatz: 11</div></div></div>`
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	mealSelection := doc.Find("div#meal")
+
+	t.Run("expect unknown allergen codes to be skipped", func(t *testing.T) {
+		want := []string{AllergenGluten, AllergenMolluscs}
+		report := ParseReport{}
+		got := parseLabelCodes(mealSelection, "div", "Allergen:", cgmAllergenCodes, &report)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the allergens %v but got %v", want, got)
+		}
+		if len(report.Warnings) != 1 {
+			t.Fatalf("expected a warning about the unknown code but got %v", report.Warnings)
+		}
+	})
+
+	t.Run("expect multi digit additive codes", func(t *testing.T) {
+		want := []string{AdditiveNitrite}
+		got := parseLabelCodes(mealSelection, "div", "Zusatz:", cgmAdditiveCodes, &ParseReport{})
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected the additives %v but got %v", want, got)
+		}
+	})
+}
+
+func TestDayStates(t *testing.T) {
+	_, report, err := CrawlCurrentWeek(context.Background(), "file://bistro.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("expect a state for every day of the week", func(t *testing.T) {
+		if len(report.Days) != 5 {
+			t.Fatalf("expected 5 days but got %+v", report.Days)
+		}
+	})
+
+	t.Run("expect a day with meals to be open", func(t *testing.T) {
+		monday := report.Days[0]
+		if monday.Id != "cgm-2020-06-08" || monday.Date != "2020-06-08" || !monday.IsOpen() || monday.MealCount != 5 {
+			t.Fatalf("expected 2020-06-08 to be open with 5 meals but got %+v", monday)
+		}
+	})
+
+	t.Run("expect a holiday to be closed", func(t *testing.T) {
+		thursday := report.Days[3]
+		if thursday.Date != "2020-06-11" || thursday.State != DayClosed || thursday.Reason != "Fronleichnam" {
+			t.Fatalf("expected 2020-06-11 to be closed for Fronleichnam but got %+v", thursday)
+		}
+	})
+}
+
+func TestMalformedPageParsing(t *testing.T) {
+	profile, _ := ActiveProfile(DefaultSourceName)
+	header := `<div class="table-col-header"><b>Montag<br>8.6.2020</b></div>`
+	meal := `<div id="meal"><p class="menuName">Suppe</p><p class="preis"><b>3,10&euro;</b></p></div>`
+
+	t.Run("expect an error with the failed selector for a page without days", func(t *testing.T) {
+		_, report, err := cgmSource{}.ParseWeek(strings.NewReader(header))
+		if err == nil || report.FailedSelector != profile.Selectors.Day {
+			t.Fatalf("expected a failed selector %s but got %q", profile.Selectors.Day, report.FailedSelector)
+		}
+	})
+
+	t.Run("expect an error with the failed selector for a page without date headers", func(t *testing.T) {
+		_, report, err := cgmSource{}.ParseWeek(strings.NewReader(`<div id="day">` + meal + `</div>`))
+		if err == nil || report.FailedSelector != profile.Selectors.Date {
+			t.Fatalf("expected a failed selector %s but got %q", profile.Selectors.Date, report.FailedSelector)
+		}
+	})
+
+	t.Run("expect a day with an invalid date to be skipped", func(t *testing.T) {
+		invalidHeader := `<div class="table-col-header"><b>Dienstag<br>31.2.2020</b></div>`
+		page := header + invalidHeader + `<div id="day">` + meal + `</div><div id="day">` + meal + `</div>`
+		meals, report, err := cgmSource{}.ParseWeek(strings.NewReader(page))
+		if err != nil || len(meals) != 1 || len(report.SkippedNodes) != 2 {
+			t.Fatalf("expected 1 meal and 2 skipped nodes but got %d meals and %+v", len(meals), report)
+		}
+	})
+
+	t.Run("expect more days than date headers to be skipped", func(t *testing.T) {
+		page := header + `<div id="day">` + meal + `</div><div id="day">` + meal + `</div>`
+		meals, report, err := cgmSource{}.ParseWeek(strings.NewReader(page))
+		if err != nil || len(meals) != 1 || len(report.SkippedNodes) != 1 || len(report.Warnings) != 1 {
+			t.Fatalf("expected 1 meal, 1 skipped node and 1 warning but got %d meals and %+v", len(meals), report)
+		}
+	})
+
+	t.Run("expect a meal with an invalid price to be skipped", func(t *testing.T) {
+		invalidMeal := `<div id="meal"><p class="menuName">Suppe</p><p class="preis"><b>drei Euro</b></p></div>`
+		meals, report, err := cgmSource{}.ParseWeek(strings.NewReader(header + `<div id="day">` + invalidMeal + `</div>`))
+		if err != nil || len(meals) != 0 || len(report.SkippedNodes) != 1 {
+			t.Fatalf("expected no meals and 1 skipped node but got %d meals and %+v", len(meals), report)
+		}
+		if len(report.Days) != 1 || report.Days[0].State != DayUnparseable {
+			t.Fatalf("expected the day to be unparseable but got %+v", report.Days)
+		}
+	})
+
+	t.Run("expect a meal without price on an open day to be reported", func(t *testing.T) {
+		unpricedMeal := `<div id="meal"><p class="menuName">Nachtisch</p></div>`
+		meals, report, err := cgmSource{}.ParseWeek(strings.NewReader(header + `<div id="day">` + meal + unpricedMeal + `</div>`))
+		if err != nil || len(meals) != 1 || len(report.Warnings) != 1 {
+			t.Fatalf("expected 1 meal and 1 warning but got %d meals and %+v", len(meals), report)
+		}
+	})
+
+	t.Run("expect a supplement without price column to be skipped", func(t *testing.T) {
+		supplement := `<div style="padding-left:10px"><div>Reis</div></div>`
+		mealWithSupplement := strings.Replace(meal, "</div>", supplement+"</div>", 1)
+		meals, report, err := cgmSource{}.ParseWeek(strings.NewReader(header + `<div id="day">` + mealWithSupplement + `</div>`))
+		if err != nil || len(meals) != 1 || len(meals[0].OptionalSupplements) != 0 || len(report.SkippedNodes) != 1 {
+			t.Fatalf("expected 1 meal without supplements and 1 skipped node but got %d meals and %+v", len(meals), report)
+		}
+	})
+}
+
 func TestPositiveDateParsing(t *testing.T) {
 
 	want := "https://bistro.cgm.ag/index.php?day=31&month=12&year=2020"
@@ -81,6 +230,34 @@ func TestPositiveDateParsing(t *testing.T) {
 	})
 }
 
+func TestSourceRegistry(t *testing.T) {
+
+	t.Run("expect the cgm source to be registered as default", func(t *testing.T) {
+		if _, err := GetSource(DefaultSourceName); err != nil {
+			t.Fatalf("expected the default source to be registered but got %s", err)
+		}
+	})
+
+	t.Run("expect an error for an unknown source", func(t *testing.T) {
+		if _, err := GetSource("unknown"); err == nil {
+			t.Fatalf("expected an error for an unknown source")
+		}
+	})
+
+	t.Run("expect crawling an unknown source to fail", func(t *testing.T) {
+		if _, _, err := CrawlSource(context.Background(), "unknown", "file://bistro.html", ""); err == nil {
+			t.Fatalf("expected an error when crawling an unknown source")
+		}
+	})
+
+	t.Run("expect the registered source names", func(t *testing.T) {
+		names := SourceNames()
+		if len(names) != 1 || names[0] != DefaultSourceName {
+			t.Fatalf("expected only the source %s but got %v", DefaultSourceName, names)
+		}
+	})
+}
+
 func isDate(dateString string, t *testing.T) bool {
 	_, err := time.Parse("2006-01-02", dateString)
 	if err != nil {